@@ -2,30 +2,47 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"go.arsenm.dev/lure-repo-bot/internal/analyze"
-	"go.arsenm.dev/lure-repo-bot/internal/shutils"
+	"go.arsenm.dev/lure-repo-bot/internal/sandbox"
 	"go.arsenm.dev/lure-repo-bot/internal/spdx"
-	"mvdan.cc/sh/v3/expand"
-	"mvdan.cc/sh/v3/interp"
 	"mvdan.cc/sh/v3/syntax"
 )
 
-func init() {
-	err := spdx.Update()
-	if err != nil {
-		fatalErr(err)
+func main() {
+	checkURLs := flag.Bool("check-urls", false, "Check that the homepage and sources can be downloaded")
+	spdxList := flag.String("spdx-list", "", "Path to a local SPDX license list JSON file, used instead of downloading it")
+	spdxExceptions := flag.String("spdx-exceptions", "", "Path to a local SPDX exception list JSON file, used instead of downloading it")
+	updateSPDX := flag.Bool("update-spdx", false, "Download the latest SPDX lists if the cached ones are missing or more than a day old")
+	flag.Parse()
+
+	if *spdxList != "" || *spdxExceptions != "" {
+		err := spdx.LoadFiles(*spdxList, *spdxExceptions)
+		if err != nil {
+			fatalErr(err)
+		}
+	} else if *updateSPDX {
+		spdx.Load()
+	} else {
+		// Downloading the lists could make the analyzer hang without
+		// network access, so the cached or embedded lists are used
+		err := spdx.LoadCache()
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			fatalErr(err)
+		}
 	}
-}
 
-func main() {
 	ctx := context.Background()
 
 	var files []*os.File
-	for _, arg := range os.Args[1:] {
+	for _, arg := range flag.Args() {
 		file, err := os.Open(arg)
 		if err != nil {
 			fatalErr(err)
@@ -45,60 +62,43 @@ func main() {
 			fatalErr(err)
 		}
 
-		var nopRWC shutils.NopRWC
-		runner, err := interp.New(
-			interp.Env(expand.ListEnviron()),
-			interp.StdIO(nopRWC, nopRWC, os.Stderr),
-			interp.ExecHandler(shutils.NopExec),
-			interp.ReadDirHandler(shutils.NopReadDir),
-			interp.OpenHandler(shutils.NopOpen),
-			interp.StatHandler(shutils.NopStat),
-		)
+		dir, err := filepath.Abs(filepath.Dir(file.Name()))
 		if err != nil {
 			fatalErr(err)
 		}
 
-		err = runner.Run(ctx, fl)
-		if err != nil {
+		var findings []analyze.Finding
+		res, err := sandbox.Evaluate(ctx, fl, sandbox.DirFS(dir))
+		if finding, ok := analyze.EvalFinding(err); ok {
+			findings = []analyze.Finding{finding}
+		} else if err != nil {
 			fatalErr(err)
-		}
+		} else {
+			findings, err = analyze.AnalyzeScript(res, analyze.Options{
+				Dir: filepath.Base(dir),
+			})
+			if err != nil {
+				fatalErr(err)
+			}
 
-		findings, err := analyze.AnalyzeScript(runner, fl)
-		if err != nil {
-			fatalErr(err)
+			if *checkURLs {
+				findings = append(findings, analyze.Reachability{}.Check(ctx, res)...)
+			}
 		}
 
 		flName := strings.TrimPrefix(file.Name(), wd)
 		flName = strings.TrimPrefix(flName, "/")
 
-		fmt.Println(flName + ":")
 		if len(findings) == 0 {
-			fmt.Println("\tNo issues found!")
+			fmt.Println(flName + ": No issues found!")
 		} else {
 			issuesFound = true
 			for _, finding := range findings {
-				var name string
-				if finding.Index != nil {
-					name = fmt.Sprintf(
-						"%s[%v] %s",
-						finding.ItemName,
-						finding.Index,
-						finding.ItemType,
-					)
-				} else {
-					name = fmt.Sprintf(
-						"%s %s",
-						finding.ItemName,
-						finding.ItemType,
-					)
-				}
-
-				msg := fmt.Sprintf(finding.Msg, name)
+				msg := finding.Message(false)
 
-				if finding.ExtraMsg == "" {
-					fmt.Printf("\tLine %d: %s\n", finding.Line, msg)
-				} else {
-					fmt.Printf("\tLine %d: %s\n\t\t%s\n", finding.Line, msg, finding.ExtraMsg)
+				fmt.Printf("%s:%d:%d: %s\n", flName, finding.StartLine, finding.StartCol, msg)
+				if finding.ExtraMsg != "" {
+					fmt.Printf("\t%s\n", finding.ExtraMsg)
 				}
 			}
 		}
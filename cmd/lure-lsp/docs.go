@@ -0,0 +1,166 @@
+package main
+
+import (
+	"strings"
+
+	"go.arsenm.dev/lure-repo-bot/internal/analyze"
+	"golang.org/x/exp/slices"
+)
+
+// varDocs contains the documentation for the variables
+// that LURE reads from scripts
+var varDocs = map[string]string{
+	"name":          "The name of the package",
+	"version":       "The version of the package",
+	"release":       "The release number of the package. It should be incremented whenever the script changes without a new version, and reset to 1 when the version changes.",
+	"epoch":         "A number that overrides the version when comparing packages. It should only be used when the versioning scheme changes.",
+	"desc":          "A short description of the package",
+	"homepage":      "The URL of the package's website",
+	"maintainer":    "The maintainer of the package, in the format `Name <email>`",
+	"architectures": "The architectures the package can be built for. Use `all` for packages that don't depend on the architecture.",
+	"license":       "The SPDX identifiers of the package's licenses",
+	"provides":      "The names of the packages this package provides",
+	"conflicts":     "The names of the packages this package conflicts with",
+	"deps":          "The packages this package depends on at runtime",
+	"build_deps":    "The packages needed to build this package",
+	"opt_deps":      "Optional dependencies, with an optional description after `: `",
+	"replaces":      "The names of the packages this package replaces",
+	"sources":       "The URLs of the files to download before building the package. Git repositories use the `git+` prefix, and parameters can be added with `?~name=value`.",
+	"checksums":     "The SHA256 checksums of the sources, in the same order. Use `SKIP` to skip a checksum.",
+	"backup":        "The configuration files that should be kept when the package is upgraded or removed",
+	"scripts":       "A map of hook names to scripts that are run when the package is installed, upgraded, or removed",
+	"build_vars":    "Assignments, like `NAME=value`, that are set while building the package",
+	"auto_req":      "Whether dependencies should be detected automatically",
+	"auto_prov":     "Whether the libraries the package provides should be detected automatically",
+	"firmware":      "The firmware files that the package provides",
+	"group":         "The RPM group of the package, like `Applications/System`",
+	"section":       "The Debian section of the package, like `utils`",
+	"priority":      "The Debian priority of the package, like `optional`",
+}
+
+// lureVarDocs contains the documentation for the variables
+// that LURE provides to scripts
+var lureVarDocs = map[string]string{
+	"srcdir":             "The directory the sources are downloaded and extracted into",
+	"pkgdir":             "The directory the package's files must be installed into",
+	"scriptdir":          "The directory containing the script",
+	"ARCH":               "The architecture the package is being built for",
+	"NCPU":               "The number of CPUs on the machine building the package",
+	"DISTRO_NAME":        "The name of the distro, from /etc/os-release",
+	"DISTRO_PRETTY_NAME": "The pretty name of the distro, from /etc/os-release",
+	"DISTRO_ID":          "The ID of the distro, from /etc/os-release",
+	"DISTRO_VERSION_ID":  "The version of the distro, from /etc/os-release",
+	"DISTRO_ID_LIKE":     "The IDs of the distros this distro is based on, from /etc/os-release",
+}
+
+// funcDocs contains the documentation for the
+// functions that LURE runs
+var funcDocs = map[string]string{
+	"version": "Prints the version of the package. It's only used for `-git` packages, and runs after the sources are downloaded.",
+	"prepare": "Prepares the sources for building, for example by applying patches",
+	"build":   "Builds the package",
+	"package": "Installs the package's files into `$pkgdir`",
+}
+
+// hoverDoc returns the documentation for word
+func hoverDoc(word string) (string, bool) {
+	if doc, ok := lureVarDocs[word]; ok {
+		return "```sh\n$" + word + "\n```\n\n" + doc + "\n\nProvided by LURE", true
+	}
+
+	if doc, ok := funcDocs[word]; ok {
+		return "```sh\n" + word + "()\n```\n\n" + doc, true
+	}
+
+	base, suffix, ok := analyze.ParseVarName(word)
+	if !ok || varDocs[base] == "" {
+		return "", false
+	}
+
+	doc := "```sh\n" + word + "\n```\n\n" + varDocs[base]
+	if suffix != "" {
+		doc += "\n\nOverride of `" + base + "` for `" + suffix + "`"
+	}
+	return doc, true
+}
+
+// completions returns the completion items for word,
+// which is the partial word before the cursor
+func completions(word string) []CompletionItem {
+	var out []CompletionItem
+
+	// If the word is a variable followed by an underscore,
+	// suggest override suffixes
+	if base, suffix, ok := analyze.ParseVarName(word); ok && word != base {
+		return overrideCompletions(base, suffix)
+	}
+
+	for _, name := range analyze.ScriptVars {
+		item := CompletionItem{
+			Label:  name,
+			Kind:   completionVariable,
+			Detail: "LURE variable",
+		}
+		if doc, ok := varDocs[name]; ok {
+			item.Documentation = &MarkupContent{"markdown", doc}
+		}
+		out = append(out, item)
+	}
+
+	for name, doc := range lureVarDocs {
+		out = append(out, CompletionItem{
+			Label:         name,
+			Kind:          completionVariable,
+			Detail:        "Provided by LURE",
+			Documentation: &MarkupContent{"markdown", doc},
+		})
+	}
+
+	for name, doc := range funcDocs {
+		out = append(out, CompletionItem{
+			Label:         name,
+			Kind:          completionFunction,
+			Detail:        "LURE function",
+			Documentation: &MarkupContent{"markdown", doc},
+		})
+	}
+
+	return out
+}
+
+// overrideCompletions returns the override variants of base.
+// If suffix starts with a distro, architectures are suggested
+// after it, for distro_arch overrides.
+func overrideCompletions(base, suffix string) []CompletionItem {
+	var out []CompletionItem
+	add := func(suffix, detail string) {
+		out = append(out, CompletionItem{
+			Label:         base + "_" + suffix,
+			Kind:          completionVariable,
+			Detail:        detail,
+			Documentation: &MarkupContent{"markdown", varDocs[base]},
+		})
+	}
+
+	// all can only be used in the architectures
+	// variable, not as an override suffix
+	distro, _, hasArch := strings.Cut(suffix, "_")
+	if hasArch && slices.Contains(analyze.Distros, distro) {
+		for _, arch := range analyze.Architectures {
+			if arch != "all" {
+				add(distro+"_"+arch, "Override for "+arch+" on "+distro)
+			}
+		}
+		return out
+	}
+
+	for _, distro := range analyze.Distros {
+		add(distro, "Override for "+distro)
+	}
+	for _, arch := range analyze.Architectures {
+		if arch != "all" {
+			add(arch, "Override for "+arch)
+		}
+	}
+	return out
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"io/fs"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"go.arsenm.dev/lure-repo-bot/internal/analyze"
+	"go.arsenm.dev/lure-repo-bot/internal/sandbox"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// document is an open text document
+type document struct {
+	uri     string
+	version int
+	text    string
+	// lines contains the byte offset of the start of each line
+	lines []int
+
+	// findings contains the results of the last analysis
+	findings []analyze.Finding
+	// cancel cancels the analysis that's currently running
+	cancel context.CancelFunc
+}
+
+func newDocument(uri string, version int, text string) *document {
+	d := &document{uri: uri, version: version, text: text}
+	d.lines = []int{0}
+	for i, c := range text {
+		if c == '\n' {
+			d.lines = append(d.lines, i+1)
+		}
+	}
+	return d
+}
+
+// line returns the text of line n, starting at 0
+func (d *document) line(n int) string {
+	if n < 0 || n >= len(d.lines) {
+		return ""
+	}
+	start, end := d.lines[n], len(d.text)
+	if n+1 < len(d.lines) {
+		end = d.lines[n+1]
+	}
+	return strings.TrimRight(d.text[start:end], "\r\n")
+}
+
+// position converts a line and byte column from the
+// shell parser, both starting at 1, to an LSP position,
+// which counts UTF-16 code units.
+func (d *document) position(line, col uint) Position {
+	if line == 0 {
+		return Position{}
+	}
+
+	text := d.line(int(line) - 1)
+	off := int(col) - 1
+	if off > len(text) {
+		off = len(text)
+	} else if off < 0 {
+		off = 0
+	}
+	return Position{Line: line - 1, Character: utf16Len(text[:off])}
+}
+
+// offset converts an LSP position to a byte offset in the line it's on
+func (d *document) offset(p Position) int {
+	text := d.line(int(p.Line))
+	units := uint(0)
+	for i, r := range text {
+		if units >= p.Character {
+			return i
+		}
+		units += utf16Len(string(r))
+	}
+	return len(text)
+}
+
+// findingRange returns the LSP range of a finding
+func (d *document) findingRange(f analyze.Finding) Range {
+	return Range{
+		Start: d.position(f.StartLine, f.StartCol),
+		End:   d.position(f.EndLine, f.EndCol),
+	}
+}
+
+// editRange returns the LSP range of a fix edit
+func (d *document) editRange(r analyze.Range) Range {
+	start := d.position(r.Start.Line(), r.Start.Col())
+	end := start
+	if r.End.IsValid() {
+		end = d.position(r.End.Line(), r.End.Col())
+	}
+	return Range{start, end}
+}
+
+// analyze runs the analyzer on the document's text
+func (d *document) analyze(ctx context.Context) ([]analyze.Finding, error) {
+	fl, err := syntax.NewParser().Parse(strings.NewReader(d.text), "lure.sh")
+	if err != nil {
+		if perr, ok := err.(syntax.ParseError); ok {
+			return []analyze.Finding{parseFinding(perr)}, nil
+		}
+		return nil, err
+	}
+
+	var (
+		opts analyze.Options
+		fsys fs.FS
+	)
+	if dir, ok := uriDir(d.uri); ok {
+		fsys = sandbox.DirFS(dir)
+		opts.Dir = filepath.Base(dir)
+	}
+
+	res, err := sandbox.Evaluate(ctx, fl, fsys)
+	if finding, ok := analyze.EvalFinding(err); ok {
+		return []analyze.Finding{finding}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return analyze.AnalyzeScript(res, opts)
+}
+
+// diagnostics converts the document's findings to LSP diagnostics
+func (d *document) diagnostics() []Diagnostic {
+	out := make([]Diagnostic, 0, len(d.findings))
+	for _, finding := range d.findings {
+		out = append(out, d.diagnostic(finding))
+	}
+	return out
+}
+
+func (d *document) diagnostic(finding analyze.Finding) Diagnostic {
+	diag := Diagnostic{
+		Range:    d.findingRange(finding),
+		Severity: severityError,
+		Code:     finding.Rule,
+		Source:   "lure",
+		Message:  findingMsg(finding),
+	}
+
+	if finding.Severity == analyze.SeverityWarning {
+		diag.Severity = severityWarning
+	}
+
+	if finding.Rule != "" {
+		diag.CodeDescription = &CodeDescription{analyze.RuleDocURL(finding.Rule)}
+	}
+
+	return diag
+}
+
+// parseFinding converts a syntax error into a finding,
+// so that it can be shown like any other problem
+func parseFinding(perr syntax.ParseError) analyze.Finding {
+	f := analyze.Finding{
+		ItemType: "script",
+		ItemName: "lure.sh",
+		Msg:      "The %s couldn't be parsed: " + strings.ReplaceAll(perr.Text, "%", "%%"),
+	}
+	f.SetRange(analyze.Range{Start: perr.Pos})
+	return f
+}
+
+func findingMsg(finding analyze.Finding) string {
+	msg := finding.Message(false)
+
+	if finding.ExtraMsg != "" {
+		msg += "\n" + finding.ExtraMsg
+	}
+
+	return msg
+}
+
+// uriDir returns the directory containing the file at uri
+func uriDir(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+	return filepath.Dir(filepath.FromSlash(u.Path)), true
+}
+
+func utf16Len(s string) uint {
+	n := uint(0)
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		s = s[size:]
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+
+	"go.arsenm.dev/lure-repo-bot/internal/analyze"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// The second line contains a 2-byte character, which is one UTF-16
+// code unit, and a 4-byte character, which is two code units
+const docText = "name=foo\r\ndesc=\"ö😀x\"\n\nend"
+
+func TestDocumentLine(t *testing.T) {
+	doc := newDocument("file:///lure.sh", 1, docText)
+
+	tests := []struct {
+		line   int
+		wanted string
+	}{
+		{0, "name=foo"},
+		{1, `desc="ö😀x"`},
+		{2, ""},
+		{3, "end"},
+		{4, ""},
+		{-1, ""},
+	}
+
+	for _, tt := range tests {
+		if got := doc.line(tt.line); got != tt.wanted {
+			t.Errorf("line %d: got %q, wanted %q", tt.line, got, tt.wanted)
+		}
+	}
+}
+
+func TestDocumentPosition(t *testing.T) {
+	doc := newDocument("file:///lure.sh", 1, docText)
+
+	tests := []struct {
+		name   string
+		line   uint
+		col    uint
+		wanted Position
+	}{
+		{"start", 1, 1, Position{0, 0}},
+		{"ascii", 1, 6, Position{0, 5}},
+		{"before 2-byte character", 2, 7, Position{1, 6}},
+		{"after 2-byte character", 2, 9, Position{1, 7}},
+		{"after 4-byte character", 2, 13, Position{1, 9}},
+		{"end of line", 2, 15, Position{1, 11}},
+		{"past end of line", 2, 40, Position{1, 11}},
+		{"crlf", 1, 9, Position{0, 8}},
+		{"unknown", 0, 0, Position{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := doc.position(tt.line, tt.col); got != tt.wanted {
+				t.Errorf("got %v, wanted %v", got, tt.wanted)
+			}
+		})
+	}
+}
+
+func TestDocumentOffset(t *testing.T) {
+	doc := newDocument("file:///lure.sh", 1, docText)
+
+	tests := []struct {
+		name   string
+		pos    Position
+		wanted int
+	}{
+		{"start", Position{0, 0}, 0},
+		{"ascii", Position{0, 5}, 5},
+		{"before 2-byte character", Position{1, 6}, 6},
+		{"after 2-byte character", Position{1, 7}, 8},
+		{"after 4-byte character", Position{1, 9}, 12},
+		{"inside 4-byte character", Position{1, 8}, 12},
+		{"end of line", Position{1, 11}, 14},
+		{"past end of line", Position{1, 50}, 14},
+		{"past end of document", Position{9, 3}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := doc.offset(tt.pos); got != tt.wanted {
+				t.Errorf("got %d, wanted %d", got, tt.wanted)
+			}
+		})
+	}
+}
+
+func TestDocumentRanges(t *testing.T) {
+	doc := newDocument("file:///lure.sh", 1, docText)
+
+	f := analyze.Finding{StartLine: 2, StartCol: 7, EndLine: 2, EndCol: 13}
+	if got, wanted := doc.findingRange(f), (Range{Position{1, 6}, Position{1, 9}}); got != wanted {
+		t.Errorf("got finding range %v, wanted %v", got, wanted)
+	}
+
+	// Edits without an end are insertions
+	start := syntax.NewPos(15, 2, 9)
+	got := doc.editRange(analyze.Range{Start: start})
+	if wanted := (Range{Position{1, 7}, Position{1, 7}}); got != wanted {
+		t.Errorf("got edit range %v, wanted %v", got, wanted)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"sync"
+)
+
+// JSON-RPC error codes used by LSP
+const (
+	codeParseError     = -32700
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+// message is a JSON-RPC 2.0 request, response, or notification.
+// Notifications don't have an ID.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  any              `json:"result,omitempty"`
+	Error   *rpcError        `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// conn reads and writes JSON-RPC messages using the
+// Content-Length framing from the LSP base protocol
+type conn struct {
+	r *textproto.Reader
+	// resync is true if the last message's header was invalid,
+	// so the start of the next message has to be found
+	resync bool
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{
+		r: textproto.NewReader(bufio.NewReader(r)),
+		w: w,
+	}
+}
+
+// read reads the next message from the connection. If a message
+// is invalid, an *rpcError is returned, and the next call skips
+// to the next message.
+func (c *conn) read() (*message, error) {
+	if c.resync {
+		err := c.skipToHeader()
+		if err != nil {
+			return nil, err
+		}
+		c.resync = false
+	}
+
+	header, err := c.r.ReadMIMEHeader()
+	var perr textproto.ProtocolError
+	if errors.As(err, &perr) {
+		c.resync = true
+		return nil, &rpcError{codeParseError, err.Error()}
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Without a valid length, there's no way to know where
+	// the content ends, so it's skipped along with the header
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		c.resync = true
+		return nil, &rpcError{codeParseError, fmt.Sprintf("invalid Content-Length header: %q", header.Get("Content-Length"))}
+	}
+
+	data := make([]byte, length)
+	_, err = io.ReadFull(c.r.R, data)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &message{}
+	err = json.Unmarshal(data, msg)
+	if err != nil {
+		return nil, &rpcError{codeParseError, err.Error()}
+	}
+	return msg, nil
+}
+
+// skipToHeader discards input until the start of a
+// Content-Length header, which begins the next message
+func (c *conn) skipToHeader() error {
+	prefix := []byte("Content-Length:")
+	for {
+		data, err := c.r.R.Peek(len(prefix))
+		if bytes.Equal(data, prefix) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		_, err = c.r.R.Discard(1)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (c *conn) write(msg *message) error {
+	msg.JSONRPC = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err = fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(data))
+	if err != nil {
+		return err
+	}
+	_, err = c.w.Write(data)
+	return err
+}
+
+// reply sends a response to the request with the given ID.
+// If err is not nil, an error response is sent instead.
+func (c *conn) reply(id *json.RawMessage, result any, err error) error {
+	// Responses must have an ID, which is null
+	// if the request's ID couldn't be read
+	if id == nil {
+		null := json.RawMessage("null")
+		id = &null
+	}
+
+	if err != nil {
+		var rerr *rpcError
+		if !errors.As(err, &rerr) {
+			rerr = &rpcError{codeInternalError, err.Error()}
+		}
+		return c.write(&message{ID: id, Error: rerr})
+	}
+
+	// A null result must still be sent, so it
+	// can't be omitted like the other fields
+	if result == nil {
+		result = json.RawMessage("null")
+	}
+	return c.write(&message{ID: id, Result: result})
+}
+
+// notify sends a notification to the client
+func (c *conn) notify(method string, params any) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.write(&message{Method: method, Params: data})
+}
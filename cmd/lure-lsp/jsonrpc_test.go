@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// frame adds a Content-Length header to content
+func frame(content string) string {
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(content), content)
+}
+
+func TestConnRead(t *testing.T) {
+	input := frame(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`) +
+		"Content-Length: 40\r\nContent-Type: application/vscode-jsonrpc; charset=utf-8\r\n\r\n" +
+		`{"jsonrpc":"2.0","method":"initialized"}` +
+		frame(`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"text":"é"}}}`)
+
+	c := newConn(strings.NewReader(input), io.Discard)
+	for _, method := range []string{"initialize", "initialized", "textDocument/didOpen"} {
+		msg, err := c.read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Method != method {
+			t.Errorf("got method %q, wanted %q", msg.Method, method)
+		}
+	}
+
+	_, err := c.read()
+	if err != io.EOF {
+		t.Errorf("got %v, wanted EOF", err)
+	}
+}
+
+func TestConnReadInvalid(t *testing.T) {
+	next := frame(`{"jsonrpc":"2.0","id":2,"method":"next"}`)
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"invalid length", "Content-Length: abc\r\n\r\n{}"},
+		{"negative length", "Content-Length: -5\r\n\r\n{}"},
+		{"missing length", "Content-Type: application/json\r\n\r\n{}"},
+		{"invalid json", frame(`{"jsonrpc":`)},
+		{"malformed header", "not a header\r\n\r\n{}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newConn(strings.NewReader(tt.input+next), io.Discard)
+
+			_, err := c.read()
+			var rerr *rpcError
+			if !errors.As(err, &rerr) || rerr.Code != codeParseError {
+				t.Fatalf("got %v, wanted a parse error", err)
+			}
+
+			// The connection should recover and read the next message
+			msg, err := c.read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.Method != "next" {
+				t.Errorf("got method %q, wanted %q", msg.Method, "next")
+			}
+		})
+	}
+}
+
+func TestConnReadInvalidAtEnd(t *testing.T) {
+	c := newConn(strings.NewReader("Content-Length: abc\r\n\r\n{}"), io.Discard)
+
+	_, err := c.read()
+	if _, ok := err.(*rpcError); !ok {
+		t.Fatalf("got %v, wanted an *rpcError", err)
+	}
+
+	_, err = c.read()
+	if err != io.EOF {
+		t.Errorf("got %v, wanted EOF", err)
+	}
+}
+
+func TestConnWrite(t *testing.T) {
+	out := &bytes.Buffer{}
+	c := newConn(strings.NewReader(""), out)
+
+	err := c.notify("window/logMessage", map[string]string{"message": "é"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The length is in bytes, not characters
+	content := `{"jsonrpc":"2.0","method":"window/logMessage","params":{"message":"é"}}`
+	if out.String() != frame(content) {
+		t.Errorf("got %q, wanted %q", out.String(), frame(content))
+	}
+}
+
+func TestConnReply(t *testing.T) {
+	id := json.RawMessage("7")
+
+	tests := []struct {
+		name   string
+		id     *json.RawMessage
+		result any
+		err    error
+		wanted string
+	}{
+		{"result", &id, []int{1}, nil, `{"jsonrpc":"2.0","id":7,"result":[1]}`},
+		{"null result", &id, nil, nil, `{"jsonrpc":"2.0","id":7,"result":null}`},
+		{"rpc error", &id, nil, &rpcError{codeMethodNotFound, "nope"}, `{"jsonrpc":"2.0","id":7,"error":{"code":-32601,"message":"nope"}}`},
+		{"other error", &id, nil, errors.New("failed"), `{"jsonrpc":"2.0","id":7,"error":{"code":-32603,"message":"failed"}}`},
+		{"null id", nil, nil, &rpcError{codeParseError, "bad"}, `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"bad"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			c := newConn(strings.NewReader(""), out)
+
+			err := c.reply(tt.id, tt.result, tt.err)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if out.String() != frame(tt.wanted) {
+				t.Errorf("got %q, wanted %q", out.String(), frame(tt.wanted))
+			}
+		})
+	}
+}
+
+func TestServeInvalidMessage(t *testing.T) {
+	input := "Content-Length: x\r\n\r\n{}" +
+		frame(`{"jsonrpc":"2.0","id":1,"method":"shutdown"}`)
+
+	out := &bytes.Buffer{}
+	s := newServer(newConn(strings.NewReader(input), out))
+	err := s.serve()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The invalid message gets an error with a null ID,
+	// and the server keeps handling the next messages
+	replies := strings.SplitAfter(out.String(), "}Content-Length")
+	wanted := []string{`"id":null,"error":{"code":-32700,`, `"id":1,"result":null`}
+	if len(replies) != len(wanted) {
+		t.Fatalf("got %d replies, wanted %d: %q", len(replies), len(wanted), out.String())
+	}
+
+	for i, reply := range replies {
+		if !strings.Contains(reply, wanted[i]) {
+			t.Errorf("got %q, wanted it to contain %q", reply, wanted[i])
+		}
+	}
+}
@@ -0,0 +1,38 @@
+// Command lure-lsp is a language server for LURE scripts.
+// It communicates over stdin and stdout, and reports the
+// same findings as the bot as diagnostics.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"go.arsenm.dev/lure-repo-bot/internal/spdx"
+)
+
+func main() {
+	// Stdout is used for the protocol, so logs go to stderr
+	log.SetOutput(os.Stderr)
+
+	spdxList := flag.String("spdx-list", "", "Path to a local SPDX license list JSON file, used instead of downloading it")
+	spdxExceptions := flag.String("spdx-exceptions", "", "Path to a local SPDX exception list JSON file, used instead of downloading it")
+	flag.Parse()
+
+	// Local lists are never updated, since they're
+	// used when there's no network access
+	if *spdxList != "" || *spdxExceptions != "" {
+		err := spdx.LoadFiles(*spdxList, *spdxExceptions)
+		if err != nil {
+			log.Fatalln("Error loading SPDX license list:", err)
+		}
+	} else {
+		spdx.LoadAsync()
+	}
+
+	s := newServer(newConn(os.Stdin, os.Stdout))
+	err := s.serve()
+	if err != nil {
+		log.Fatalln("Error serving LSP connection:", err)
+	}
+}
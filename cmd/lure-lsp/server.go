@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+type server struct {
+	conn *conn
+
+	mu       sync.Mutex
+	docs     map[string]*document
+	shutdown bool
+}
+
+func newServer(c *conn) *server {
+	return &server{conn: c, docs: map[string]*document{}}
+}
+
+// serve handles messages until the connection is closed
+// or the client sends an exit notification
+func (s *server) serve() error {
+	for {
+		msg, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		} else if rerr, ok := err.(*rpcError); ok {
+			s.conn.reply(nil, nil, rerr)
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if !s.shutdown {
+				os.Exit(1)
+			}
+			return nil
+		}
+
+		result, err := s.handle(msg)
+
+		// Notifications don't get a response
+		if msg.ID == nil {
+			if err != nil {
+				log.Println("Error handling "+msg.Method+":", err)
+			}
+			continue
+		}
+
+		err = s.conn.reply(msg.ID, result, err)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) handle(msg *message) (any, error) {
+	switch msg.Method {
+	case "initialize":
+		return InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync:   syncFull,
+				HoverProvider:      true,
+				CompletionProvider: &CompletionOptions{TriggerCharacters: []string{"_", "$"}},
+				CodeActionProvider: true,
+			},
+			ServerInfo: ServerInfo{Name: "lure-lsp"},
+		}, nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.mu.Lock()
+		s.shutdown = true
+		for _, doc := range s.docs {
+			doc.cancel()
+		}
+		s.mu.Unlock()
+		return nil, nil
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		err := unmarshalParams(msg, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		item := params.TextDocument
+		s.update(newDocument(item.URI, item.Version, item.Text))
+		return nil, nil
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		err := unmarshalParams(msg, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		// With full sync, the last change contains the whole document
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		text := params.ContentChanges[len(params.ContentChanges)-1].Text
+		s.update(newDocument(params.TextDocument.URI, params.TextDocument.Version, text))
+		return nil, nil
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		err := unmarshalParams(msg, &params)
+		if err != nil {
+			return nil, err
+		}
+
+		s.mu.Lock()
+		if doc, ok := s.docs[params.TextDocument.URI]; ok {
+			doc.cancel()
+			delete(s.docs, params.TextDocument.URI)
+		}
+		s.mu.Unlock()
+
+		// Clear the diagnostics for the closed document
+		return nil, s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		})
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		err := unmarshalParams(msg, &params)
+		if err != nil {
+			return nil, err
+		}
+		return s.hover(params), nil
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		err := unmarshalParams(msg, &params)
+		if err != nil {
+			return nil, err
+		}
+		return s.completion(params), nil
+	case "textDocument/codeAction":
+		var params CodeActionParams
+		err := unmarshalParams(msg, &params)
+		if err != nil {
+			return nil, err
+		}
+		return s.codeActions(params), nil
+	default:
+		// Unknown notifications, like $/cancelRequest, can be ignored
+		if msg.ID == nil {
+			return nil, nil
+		}
+		return nil, &rpcError{codeMethodNotFound, "method not found: " + msg.Method}
+	}
+}
+
+// update replaces the stored document with doc and
+// starts analyzing it in the background. The analysis
+// of the previous version is canceled.
+func (s *server) update(doc *document) {
+	ctx, cancel := context.WithCancel(context.Background())
+	doc.cancel = cancel
+
+	s.mu.Lock()
+	if old, ok := s.docs[doc.uri]; ok {
+		old.cancel()
+	}
+	s.docs[doc.uri] = doc
+	s.mu.Unlock()
+
+	go func() {
+		defer cancel()
+
+		findings, err := doc.analyze(ctx)
+		if ctx.Err() != nil {
+			return
+		} else if err != nil {
+			log.Println("Error analyzing "+doc.uri+":", err)
+			return
+		}
+
+		s.mu.Lock()
+		if s.docs[doc.uri] != doc {
+			s.mu.Unlock()
+			return
+		}
+		doc.findings = findings
+		diags := doc.diagnostics()
+		s.mu.Unlock()
+
+		err = s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+			URI:         doc.uri,
+			Version:     doc.version,
+			Diagnostics: diags,
+		})
+		if err != nil {
+			log.Println("Error publishing diagnostics:", err)
+		}
+	}()
+}
+
+func (s *server) doc(uri string) *document {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.docs[uri]
+}
+
+func (s *server) hover(params TextDocumentPositionParams) *Hover {
+	doc := s.doc(params.TextDocument.URI)
+	if doc == nil {
+		return nil
+	}
+
+	start, end := wordAt(doc.line(int(params.Position.Line)), doc.offset(params.Position))
+	word := doc.line(int(params.Position.Line))[start:end]
+	text, ok := hoverDoc(word)
+	if !ok {
+		return nil
+	}
+
+	line := params.Position.Line + 1
+	return &Hover{
+		Contents: MarkupContent{"markdown", text},
+		Range: &Range{
+			Start: doc.position(line, uint(start+1)),
+			End:   doc.position(line, uint(end+1)),
+		},
+	}
+}
+
+func (s *server) completion(params TextDocumentPositionParams) []CompletionItem {
+	doc := s.doc(params.TextDocument.URI)
+	if doc == nil {
+		return nil
+	}
+
+	line := doc.line(int(params.Position.Line))
+	off := doc.offset(params.Position)
+	start, _ := wordAt(line, off)
+	return completions(line[start:off])
+}
+
+// codeActions returns quick fixes for the findings
+// that overlap with the requested range
+func (s *server) codeActions(params CodeActionParams) []CodeAction {
+	doc := s.doc(params.TextDocument.URI)
+	if doc == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := []CodeAction{}
+	for _, finding := range doc.findings {
+		if finding.Fix == nil || !overlaps(doc.findingRange(finding), params.Range) {
+			continue
+		}
+
+		edits := make([]TextEdit, len(finding.Fix.Edits))
+		for i, edit := range finding.Fix.Edits {
+			edits[i] = TextEdit{doc.editRange(edit.Range), edit.NewText}
+		}
+
+		out = append(out, CodeAction{
+			Title:       finding.Fix.Title,
+			Kind:        "quickfix",
+			Diagnostics: []Diagnostic{doc.diagnostic(finding)},
+			IsPreferred: true,
+			Edit: WorkspaceEdit{
+				Changes: map[string][]TextEdit{doc.uri: edits},
+			},
+		})
+	}
+	return out
+}
+
+func unmarshalParams(msg *message, v any) error {
+	err := json.Unmarshal(msg.Params, v)
+	if err != nil {
+		return &rpcError{codeInvalidParams, err.Error()}
+	}
+	return nil
+}
+
+// wordAt returns the bounds of the shell
+// word in line that contains offset off
+func wordAt(line string, off int) (start, end int) {
+	start, end = off, off
+	for start > 0 && isWordChar(line[start-1]) {
+		start--
+	}
+	for end < len(line) && isWordChar(line[end]) {
+		end++
+	}
+	return start, end
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' ||
+		(c >= 'a' && c <= 'z') ||
+		(c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9')
+}
+
+func overlaps(a, b Range) bool {
+	return !before(a.End, b.Start) && !before(b.End, a.Start)
+}
+
+func before(a, b Position) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Character < b.Character)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+// openDoc adds a document to s without analyzing it
+func openDoc(s *server, uri, text string) *document {
+	doc := newDocument(uri, 1, text)
+	s.docs[uri] = doc
+	return doc
+}
+
+func completionLabels(items []CompletionItem) []string {
+	var out []string
+	for _, item := range items {
+		out = append(out, item.Label)
+	}
+	return out
+}
+
+func TestCompletion(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		pos      Position
+		included []string
+		excluded []string
+	}{
+		{"variables", "na", Position{0, 2}, []string{"name", "build_deps", "srcdir", "package"}, nil},
+		{"override", "deps_ub", Position{0, 7}, []string{"deps_ubuntu", "deps_arm64"}, []string{"name", "deps_all"}},
+		{"distro and arch", "deps_ubuntu_", Position{0, 12}, []string{"deps_ubuntu_amd64"}, []string{"deps_ubuntu", "deps_ubuntu_all"}},
+		{"longest variable", "build_deps_", Position{0, 11}, []string{"build_deps_debian"}, []string{"build_debian"}},
+		{"after multibyte", "# é\ndesc=\"é\" deps_", Position{1, 14}, []string{"deps_fedora"}, nil},
+		{"middle of word", "deps_ubuntu", Position{0, 4}, []string{"deps", "name"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newServer(newConn(strings.NewReader(""), io.Discard))
+			openDoc(s, "file:///lure.sh", tt.text)
+
+			labels := completionLabels(s.completion(TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{"file:///lure.sh"},
+				Position:     tt.pos,
+			}))
+
+			for _, label := range tt.included {
+				if !slices.Contains(labels, label) {
+					t.Errorf("%s wasn't suggested", label)
+				}
+			}
+			for _, label := range tt.excluded {
+				if slices.Contains(labels, label) {
+					t.Errorf("%s was suggested", label)
+				}
+			}
+		})
+	}
+}
+
+func TestCompletionUnknownDocument(t *testing.T) {
+	s := newServer(newConn(strings.NewReader(""), io.Discard))
+	items := s.completion(TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{"file:///other.sh"}})
+	if items != nil {
+		t.Errorf("got %v, wanted nil", items)
+	}
+}
+
+func TestCodeActions(t *testing.T) {
+	const uri = "untitled:lure.sh"
+	text := "name=foo\nversion=1\nrelease=1\n" +
+		"package() {\n" +
+		"\tx=1\n" +
+		"\techo \"é\" $x\n" +
+		"\t[ \"$x\" == 1 ]\n" +
+		"}\n"
+
+	s := newServer(newConn(strings.NewReader(""), io.Discard))
+	doc := openDoc(s, uri, text)
+
+	findings, err := doc.analyze(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.findings = findings
+
+	tests := []struct {
+		name   string
+		rng    Range
+		titles []string
+		edits  [][]TextEdit
+	}{
+		{
+			name:   "quote",
+			rng:    Range{Position{5, 10}, Position{5, 10}},
+			titles: []string{"Quote $x"},
+			edits: [][]TextEdit{{
+				{Range{Position{5, 10}, Position{5, 10}}, `"`},
+				{Range{Position{5, 12}, Position{5, 12}}, `"`},
+			}},
+		},
+		{
+			name:   "test equals",
+			rng:    Range{Position{6, 0}, Position{6, 14}},
+			titles: []string{"Replace == with ="},
+			edits: [][]TextEdit{{
+				{Range{Position{6, 8}, Position{6, 10}}, "="},
+			}},
+		},
+		{
+			name:   "both",
+			rng:    Range{Position{5, 0}, Position{6, 9}},
+			titles: []string{"Quote $x", "Replace == with ="},
+		},
+		{
+			name: "no fix",
+			rng:  Range{Position{4, 1}, Position{4, 4}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actions := s.codeActions(CodeActionParams{
+				TextDocument: TextDocumentIdentifier{uri},
+				Range:        tt.rng,
+			})
+
+			var titles []string
+			for _, action := range actions {
+				titles = append(titles, action.Title)
+
+				if action.Kind != "quickfix" || len(action.Diagnostics) != 1 {
+					t.Errorf("got kind %q with %d diagnostics, wanted a quickfix with 1", action.Kind, len(action.Diagnostics))
+				}
+			}
+			if strings.Join(titles, ",") != strings.Join(tt.titles, ",") {
+				t.Fatalf("got %v, wanted %v", titles, tt.titles)
+			}
+
+			for i, edits := range tt.edits {
+				got := actions[i].Edit.Changes[uri]
+				if len(got) != len(edits) {
+					t.Fatalf("got %v, wanted %v", got, edits)
+				}
+				for j := range edits {
+					if got[j] != edits[j] {
+						t.Errorf("got edit %v, wanted %v", got[j], edits[j])
+					}
+				}
+			}
+		})
+	}
+}
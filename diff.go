@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// diffLines is a set of line numbers on the right
+// side of a diff that can have review comments
+// attached to them.
+type diffLines map[int]struct{}
+
+func (dl diffLines) Contains(line int) bool {
+	_, ok := dl[line]
+	return ok
+}
+
+// ContainsRange checks whether all the lines
+// from start to end (inclusive) are in dl
+func (dl diffLines) ContainsRange(start, end int) bool {
+	for line := start; line <= end; line++ {
+		if !dl.Contains(line) {
+			return false
+		}
+	}
+	return true
+}
+
+// parsePatch parses the unified diff hunks returned by the
+// GitHub API for a file and returns the lines of the new
+// version of the file that are part of the diff.
+func parsePatch(patch string) diffLines {
+	out := diffLines{}
+
+	line := 0
+	inHunk := false
+	for _, ln := range strings.Split(patch, "\n") {
+		if strings.HasPrefix(ln, "@@") {
+			start, ok := parseHunkHeader(ln)
+			inHunk = ok
+			line = start
+			continue
+		}
+
+		if !inHunk || ln == "" {
+			continue
+		}
+
+		switch ln[0] {
+		case '+', ' ':
+			out[line] = struct{}{}
+			line++
+		case '-', '\\':
+			// Removed lines only exist on the left side,
+			// and "\ No newline at end of file" isn't a line
+		}
+	}
+
+	return out
+}
+
+// parseHunkHeader returns the starting line of the new file
+// from a hunk header such as "@@ -1,4 +1,6 @@ func()"
+func parseHunkHeader(header string) (int, bool) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+		return 0, false
+	}
+
+	startStr, _, _ := strings.Cut(strings.TrimPrefix(fields[2], "+"), ",")
+	start, err := strconv.Atoi(startStr)
+	if err != nil {
+		return 0, false
+	}
+
+	return start, true
+}
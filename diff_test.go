@@ -0,0 +1,117 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestParsePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  []int
+	}{
+		{"empty", "", nil},
+		{
+			"addition",
+			"@@ -0,0 +1,3 @@\n+name=foo\n+version=1.0\n+release=1",
+			[]int{1, 2, 3},
+		},
+		{
+			"context",
+			"@@ -1,3 +1,3 @@\n name=foo\n-version=1.0\n+version=1.1\n release=1",
+			[]int{1, 2, 3},
+		},
+		{
+			"without counts",
+			"@@ -1 +1 @@\n-version=1.0\n+version=1.1",
+			[]int{1},
+		},
+		{
+			"deletion only",
+			"@@ -4,3 +4,1 @@ package() {\n \tcd foo\n-\tmake\n-\tmake install",
+			[]int{4},
+		},
+		{
+			"removed file",
+			"@@ -1,2 +0,0 @@\n-name=foo\n-version=1.0",
+			nil,
+		},
+		{
+			"no newline",
+			"@@ -1,2 +1,2 @@\n name=foo\n-version=1.0\n\\ No newline at end of file\n+version=1.1\n\\ No newline at end of file",
+			[]int{1, 2},
+		},
+		{
+			"several hunks",
+			"@@ -1,2 +1,3 @@\n name=foo\n+desc='Foo'\n version=1.0\n@@ -10,2 +11,2 @@ build() {\n-\tmake\n+\tmake -j\"$NCPU\"\n }",
+			[]int{1, 2, 3, 11, 12},
+		},
+		{
+			"invalid header",
+			"@@ invalid @@\n+name=foo\n@@ -5 +5 @@\n+version=1.0",
+			[]int{5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			for line := range parsePatch(tt.patch) {
+				got = append(got, line)
+			}
+			sort.Ints(got)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, wanted %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHunkHeader(t *testing.T) {
+	tests := []struct {
+		header string
+		start  int
+		ok     bool
+	}{
+		{"@@ -1,4 +1,6 @@", 1, true},
+		{"@@ -1,4 +12,6 @@ package() {", 12, true},
+		{"@@ -1 +1 @@", 1, true},
+		{"@@ -3,2 +0,0 @@", 0, true},
+		{"@@ -1,4 @@", 0, false},
+		{"@@ -1,4 +a,6 @@", 0, false},
+		{"@@", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			start, ok := parseHunkHeader(tt.header)
+			if start != tt.start || ok != tt.ok {
+				t.Errorf("got (%d, %v), wanted (%d, %v)", start, ok, tt.start, tt.ok)
+			}
+		})
+	}
+}
+
+func TestDiffLinesContainsRange(t *testing.T) {
+	dl := parsePatch("@@ -1,2 +1,3 @@\n a\n+b\n c\n@@ -10 +11 @@\n+d")
+
+	tests := []struct {
+		start, end int
+		want       bool
+	}{
+		{1, 3, true},
+		{2, 2, true},
+		{1, 4, false},
+		{3, 11, false},
+		{11, 11, true},
+	}
+
+	for _, tt := range tests {
+		if got := dl.ContainsRange(tt.start, tt.end); got != tt.want {
+			t.Errorf("ContainsRange(%d, %d) = %v, wanted %v", tt.start, tt.end, got, tt.want)
+		}
+	}
+}
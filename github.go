@@ -16,101 +16,140 @@ func newClient(ctx context.Context, token string) *github.Client {
 	return github.NewClient(tc)
 }
 
-func writeFindings(ctx context.Context, c *github.Client, findings []analyze.Finding, path string, pr *types.PullRequest) error {
-	comments := make([]*github.DraftReviewComment, len(findings))
-
-	for i, finding := range findings {
-		var name string
-		if finding.Index != nil {
-			name = fmt.Sprintf(
-				"`%s[%v]` %s",
-				finding.ItemName,
-				finding.Index,
-				finding.ItemType,
-			)
-		} else {
-			name = fmt.Sprintf(
-				"`%s` %s",
-				finding.ItemName,
-				finding.ItemType,
-			)
-		}
-
-		msg := fmt.Sprintf(finding.Msg, name)
-
-		if finding.ExtraMsg != "" {
-			msg += "\n\n" + finding.ExtraMsg
-		}
-
-		if finding.Line == 0 {
-			finding.Line = 1
-		}
-
-		fmt.Println(finding.Line)
+// fileResult contains the results of analyzing
+// a single lure.sh file in a PR
+type fileResult struct {
+	Path     string
+	Name     string
+	Version  string
+	Release  string
+	Findings []analyze.Finding
+	// DiffLines contains the lines of the file
+	// that are part of the PR diff
+	DiffLines diffLines
+}
 
-		comments[i] = &github.DraftReviewComment{
-			Line: github.Int(int(finding.Line)),
-			Path: github.String(path),
-			Body: github.String(msg),
-			Side: github.String("RIGHT"),
-		}
-	}
+// listFiles returns all the files changed in a PR
+func listFiles(ctx context.Context, c *github.Client, pr *types.PullRequest) ([]*github.CommitFile, error) {
+	var out []*github.CommitFile
 
-	if len(findings) > 0 {
-		rev, _, err := c.PullRequests.CreateReview(
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		fls, res, err := c.PullRequests.ListFiles(
 			ctx,
 			pr.Base.Repo.Owner.Login,
 			pr.Base.Repo.Name,
 			int(pr.Number),
-			&github.PullRequestReviewRequest{
-				Comments: comments,
-			},
+			opts,
 		)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		out = append(out, fls...)
 
-		_, _, err = c.PullRequests.SubmitReview(
-			ctx,
-			pr.Base.Repo.Owner.Login,
-			pr.Base.Repo.Name,
-			int(pr.Number),
-			*rev.ID,
-			&github.PullRequestReviewRequest{
-				Body:  github.String("Please re-request review from the bot after applying these fixes"),
-				Event: github.String("COMMENT"),
-			},
-		)
-		if err != nil {
-			return err
-		}
-	} else {
-		rev, _, err := c.PullRequests.CreateReview(
-			ctx,
-			pr.Base.Repo.Owner.Login,
-			pr.Base.Repo.Name,
-			int(pr.Number),
-			&github.PullRequestReviewRequest{},
-		)
-		if err != nil {
-			return err
+		if res.NextPage == 0 {
+			return out, nil
 		}
+		opts.Page = res.NextPage
+	}
+}
 
-		_, _, err = c.PullRequests.SubmitReview(
-			ctx,
-			pr.Base.Repo.Owner.Login,
-			pr.Base.Repo.Name,
-			int(pr.Number),
-			*rev.ID,
-			&github.PullRequestReviewRequest{
-				Body:  github.String("No issues found!"),
-				Event: github.String("APPROVE"),
-			},
-		)
-		if err != nil {
-			return err
+func writeReview(ctx context.Context, c *github.Client, results []fileResult, pr *types.PullRequest) error {
+	// PRs that don't change any scripts aren't reviewed,
+	// so that the bot doesn't approve unrelated changes
+	if len(results) == 0 {
+		return nil
+	}
+
+	comments, body, event := buildReview(results)
+
+	rev, _, err := c.PullRequests.CreateReview(
+		ctx,
+		pr.Base.Repo.Owner.Login,
+		pr.Base.Repo.Name,
+		int(pr.Number),
+		&github.PullRequestReviewRequest{
+			Comments: comments,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = c.PullRequests.SubmitReview(
+		ctx,
+		pr.Base.Repo.Owner.Login,
+		pr.Base.Repo.Name,
+		int(pr.Number),
+		*rev.ID,
+		&github.PullRequestReviewRequest{
+			Body:  github.String(body),
+			Event: github.String(event),
+		},
+	)
+	return err
+}
+
+// buildReview generates the comments, body, and
+// event of the review for the results of a PR
+func buildReview(results []fileResult) (comments []*github.DraftReviewComment, body, event string) {
+	var (
+		unanchored []unanchoredFinding
+		total      int
+	)
+
+	for _, result := range results {
+		for _, finding := range result.Findings {
+			total++
+
+			// GitHub rejects the entire review if a comment
+			// is on a line that isn't part of the diff
+			start, end := int(finding.StartLine), int(finding.EndLine)
+			if start == 0 || !result.DiffLines.Contains(start) {
+				unanchored = append(unanchored, unanchoredFinding{result.Path, finding})
+				continue
+			}
+
+			comment := &github.DraftReviewComment{
+				Line: github.Int(start),
+				Path: github.String(result.Path),
+				Body: github.String(findingMsg(finding)),
+				Side: github.String("RIGHT"),
+			}
+
+			// Multi-line comments can only be used if every
+			// line in the range is part of the diff
+			if end > start && result.DiffLines.ContainsRange(start, end) {
+				comment.StartLine = github.Int(start)
+				comment.StartSide = github.String("RIGHT")
+				comment.Line = github.Int(end)
+			}
+
+			comments = append(comments, comment)
 		}
 	}
 
-	return nil
+	body = summaryReport(results, unanchored)
+
+	event = "APPROVE"
+	if total > 0 {
+		event = "COMMENT"
+	}
+
+	return comments, body, event
+}
+
+// findingMsg generates a markdown message for a finding
+func findingMsg(finding analyze.Finding) string {
+	msg := finding.Message(true)
+
+	if finding.ExtraMsg != "" {
+		msg += "\n\n" + finding.ExtraMsg
+	}
+
+	if finding.Rule != "" {
+		msg += fmt.Sprintf("\n\n[%s](%s)", finding.Rule, analyze.RuleDocURL(finding.Rule))
+	}
+
+	return msg
 }
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"go.arsenm.dev/lure-repo-bot/internal/analyze"
+)
+
+func TestBuildReview(t *testing.T) {
+	finding := func(start, end uint) analyze.Finding {
+		return analyze.Finding{
+			ItemType:  "function",
+			ItemName:  "package",
+			Msg:       "The %s uses sudo.",
+			Rule:      analyze.RuleSudo,
+			Severity:  analyze.SeverityError,
+			StartLine: start,
+			EndLine:   end,
+		}
+	}
+
+	results := []fileResult{{
+		Path:    "foo/lure.sh",
+		Name:    "foo|bar",
+		Version: "`1.0`",
+		Release: "1",
+		Findings: []analyze.Finding{
+			finding(2, 2),
+			finding(2, 3),
+			finding(3, 5),
+			finding(8, 8),
+			finding(0, 0),
+		},
+		DiffLines: diffLines{2: {}, 3: {}, 5: {}},
+	}}
+
+	comments, body, event := buildReview(results)
+
+	if event != "COMMENT" {
+		t.Errorf("got event %q, wanted COMMENT", event)
+	}
+
+	// The finding on lines 3-5 is attached to its first line,
+	// since line 4 isn't part of the diff
+	wanted := []struct{ start, line int }{{0, 2}, {2, 3}, {0, 3}}
+	if len(comments) != len(wanted) {
+		t.Fatalf("got %d comments, wanted %d", len(comments), len(wanted))
+	}
+	for i, comment := range comments {
+		if comment.GetPath() != "foo/lure.sh" {
+			t.Errorf("comment %d: got path %q, wanted foo/lure.sh", i, comment.GetPath())
+		}
+		if comment.GetStartLine() != wanted[i].start || comment.GetLine() != wanted[i].line {
+			t.Errorf("comment %d: got lines %d-%d, wanted %d-%d", i, comment.GetStartLine(), comment.GetLine(), wanted[i].start, wanted[i].line)
+		}
+		if !strings.HasPrefix(comment.GetBody(), "The `package` function uses sudo.") {
+			t.Errorf("comment %d: got body %q", i, comment.GetBody())
+		}
+	}
+
+	row := "| `foo/lure.sh` | `foo\\|bar` | `` `1.0` `` | `1` | 5 | 0 |"
+	if !strings.Contains(body, row+"\n") {
+		t.Errorf("got body %q, wanted it to contain %q", body, row)
+	}
+	if n := strings.Count(body, "- **error** `foo/lure.sh`"); n != 2 {
+		t.Errorf("got %d unanchored findings in body, wanted 2", n)
+	}
+}
+
+func TestBuildReviewApprove(t *testing.T) {
+	results := []fileResult{{Path: "foo/lure.sh", Name: "foo", Version: "1.0", Release: "1"}}
+
+	comments, body, event := buildReview(results)
+	if event != "APPROVE" {
+		t.Errorf("got event %q, wanted APPROVE", event)
+	}
+	if len(comments) != 0 {
+		t.Errorf("got %d comments, wanted none", len(comments))
+	}
+	if !strings.HasSuffix(body, "No issues found!") {
+		t.Errorf("got body %q, wanted it to end with No issues found!", body)
+	}
+	if strings.Contains(body, "Other findings") {
+		t.Errorf("got body %q, wanted no other findings", body)
+	}
+}
@@ -2,34 +2,133 @@ package analyze
 
 import (
 	"encoding/hex"
+	"fmt"
 	"net/mail"
 	"net/url"
 	"strings"
 
-	"go.arsenm.dev/lure-repo-bot/internal/spdx"
+	"go.arsenm.dev/lure-repo-bot/internal/sandbox"
 	"golang.org/x/exp/slices"
 	"mvdan.cc/sh/v3/expand"
-	"mvdan.cc/sh/v3/interp"
-	"mvdan.cc/sh/v3/syntax"
 )
 
+// Severity represents how serious a finding is
+type Severity uint8
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "unknown"
+	}
+}
+
 type Finding struct {
 	ItemType string
 	ItemName string
-	Line     uint
 	Index    any
 	Msg      string
 	ExtraMsg string
+	Rule     string
+	Severity Severity
+
+	// StartLine and StartCol are the position of the start of the
+	// finding, and EndLine and EndCol are the position right after
+	// its end. They're all zero if the position is unknown.
+	StartLine uint
+	StartCol  uint
+	EndLine   uint
+	EndCol    uint
+
+	// Fix is a suggested fix for the finding, or nil
+	// if it can't be fixed automatically
+	Fix *Fix
+}
+
+// Fix is a set of edits that resolves a finding
+type Fix struct {
+	Title string
+	Edits []Edit
+}
+
+// Edit replaces the text in Range with NewText. If the
+// start and end of Range are the same, NewText is inserted.
+type Edit struct {
+	Range   Range
+	NewText string
 }
 
-func AnalyzeScript(r *interp.Runner, fl *syntax.File) ([]Finding, error) {
+// SetRange sets the position of the finding. If the end of
+// the range is unknown, it's set to the start.
+func (f *Finding) SetRange(r Range) {
+	f.StartLine, f.StartCol = r.Start.Line(), r.Start.Col()
+	if r.End.IsValid() {
+		f.EndLine, f.EndCol = r.End.Line(), r.End.Col()
+	} else {
+		f.EndLine, f.EndCol = f.StartLine, f.StartCol
+	}
+}
+
+// Message returns the finding's message with the item it's about
+// filled in. If markdown is true, the item's name is formatted as code.
+func (f Finding) Message(markdown bool) string {
+	name := f.ItemName
+	if f.Index != nil {
+		name = fmt.Sprintf("%s[%v]", f.ItemName, f.Index)
+	}
+
+	if markdown {
+		name = "`" + name + "`"
+	}
+
+	return fmt.Sprintf(f.Msg, name+" "+f.ItemType)
+}
+
+// escapeMsg escapes the % characters in s, so that values from
+// the script can be included in Msg, which is a format string
+func escapeMsg(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
+
+// Options contains information about a script
+// that can't be found in the script itself
+type Options struct {
+	// Dir is the name of the directory containing
+	// the script, or an empty string if it's unknown
+	Dir string
+}
+
+func AnalyzeScript(res *sandbox.Result, opts Options) ([]Finding, error) {
 	var findings []Finding
+	r, fl := res.Runner, res.File
+
+	for _, call := range res.Builtins {
+		f := Finding{
+			ItemType: "builtin",
+			ItemName: call.Args[0],
+			Msg:      "The %s isn't allowed outside of functions",
+			ExtraMsg: "LURE evaluates the whole script to read its variables, so code outside of functions must not have side effects.",
+			Rule:     RuleEvaluation,
+			Severity: SeverityWarning,
+		}
+		f.SetRange(Range{call.Pos, call.End})
+		findings = append(findings, f)
+	}
 
 	if _, ok := r.Vars["name"]; !ok {
 		findings = append(findings, Finding{
 			ItemType: "variable",
 			ItemName: "name",
 			Msg:      "The %s is required",
+			Rule:     RuleRequired,
 		})
 	}
 
@@ -38,6 +137,7 @@ func AnalyzeScript(r *interp.Runner, fl *syntax.File) ([]Finding, error) {
 			ItemType: "variable",
 			ItemName: "version",
 			Msg:      "The %s is required",
+			Rule:     RuleRequired,
 		})
 	}
 
@@ -46,6 +146,7 @@ func AnalyzeScript(r *interp.Runner, fl *syntax.File) ([]Finding, error) {
 			ItemType: "variable",
 			ItemName: "release",
 			Msg:      "The %s is required",
+			Rule:     RuleRequired,
 		})
 	}
 
@@ -54,24 +155,53 @@ func AnalyzeScript(r *interp.Runner, fl *syntax.File) ([]Finding, error) {
 			ItemType: "function",
 			ItemName: "package",
 			Msg:      "The %s is required",
+			Rule:     RuleRequired,
 		})
 	}
 
+	findings = append(findings, commandFindings(res.Commands)...)
+	findings = append(findings, accessFindings(res.Accesses)...)
+	findings = append(findings, funcFindings(r.Vars, fl)...)
+	findings = append(findings, lintFindings(r.Vars, fl)...)
+	findings = append(findings, deprecatedLicenseFindings(fl)...)
+
+	// The architectures the package supports, used
+	// to check architecture override suffixes
+	archVar := r.Vars["architectures"]
+	archs, _ := getVal(&archVar).([]string)
+
+	// The package's version, used to check the sources
+	var ver string
+
 	for name, scriptVar := range r.Vars {
 		_, scriptVar = scriptVar.Resolve(r.Env)
 		val := getVal(&scriptVar)
 
 		// Remove any override suffix, so that we
 		// check all the overrides as well
-		cutName, _, _ := strings.Cut(name, "_")
+		baseName, suffix, ok := ParseVarName(name)
+		if !ok {
+			continue
+		}
 
-		// build_vars has an underscore, and thus is a special case
-		// that must be accounted for
-		if strings.HasPrefix("name", "build_vars") {
-			cutName = "build_vars"
+		if suffix != "" && slices.Contains(overridableVars, baseName) {
+			findings = append(findings, overrideFindings(name, suffix, archs)...)
 		}
 
-		switch cutName {
+		switch baseName {
+		case "name":
+			if suffix != "" {
+				continue
+			}
+
+			checkName(val, name, opts.Dir, r.Vars, r.Funcs, &findings)
+		case "version":
+			// Overrides of version are usually helper variables, like version_major
+			if suffix != "" {
+				continue
+			}
+
+			ver, _ = checkVersion(val, name, r.Vars["release"].String(), &findings)
 		case "release":
 			valStr, ok := mustBeStr(val, name, &findings)
 			if !ok {
@@ -83,6 +213,7 @@ func AnalyzeScript(r *interp.Runner, fl *syntax.File) ([]Finding, error) {
 					ItemType: "variable",
 					ItemName: name,
 					Msg:      "The %s must be an integer",
+					Rule:     RuleNumeric,
 				})
 				continue
 			}
@@ -97,6 +228,7 @@ func AnalyzeScript(r *interp.Runner, fl *syntax.File) ([]Finding, error) {
 					ItemType: "variable",
 					ItemName: name,
 					Msg:      "The %s must be a positive integer",
+					Rule:     RuleNumeric,
 				})
 				continue
 			}
@@ -112,6 +244,7 @@ func AnalyzeScript(r *interp.Runner, fl *syntax.File) ([]Finding, error) {
 					ItemType: "variable",
 					ItemName: name,
 					Msg:      "The %s must be a valid URL",
+					Rule:     RuleURL,
 				})
 				continue
 			}
@@ -127,6 +260,7 @@ func AnalyzeScript(r *interp.Runner, fl *syntax.File) ([]Finding, error) {
 					ItemType: "variable",
 					ItemName: name,
 					Msg:      "The %s must be a valid RFC 5322 address",
+					Rule:     RuleMaintainer,
 				})
 				continue
 			}
@@ -136,6 +270,7 @@ func AnalyzeScript(r *interp.Runner, fl *syntax.File) ([]Finding, error) {
 					ItemType: "variable",
 					ItemName: name,
 					Msg:      "The %s must contain a name and email (e.g. Arsen Musayelyan <arsen@arsenm.dev>)",
+					Rule:     RuleMaintainer,
 				})
 				continue
 			}
@@ -150,49 +285,29 @@ func AnalyzeScript(r *interp.Runner, fl *syntax.File) ([]Finding, error) {
 					ItemType: "variable",
 					ItemName: name,
 					Msg:      "The %s must be set to 'all' to represent noarch/any",
+					Rule:     RuleArchitectures,
 				})
 				continue
 			}
+
+			checkArchitectures(valSlice, name, &findings)
 		case "license":
 			valSlice, ok := mustBeArray(val, name, &findings)
 			if !ok {
 				continue
 			}
 
-			for _, val := range valSlice {
-				if strings.Contains(strings.ToLower(val), "custom") {
-					continue
-				}
-
-				license := spdx.Licenses.License(val)
-				if license == nil {
-					similar := spdx.FindSimilarLicense(val)
-
-					f := Finding{
-						ItemType: "variable",
-						ItemName: name,
-						Msg:      "The %s contains an invalid SPDX license identifier: '" + val + "'.",
-						ExtraMsg: "A list of SPDX license identifiers can be found at https://spdx.org/licenses/.",
-					}
-
-					if similar != "" {
-						f.Msg += " Did you mean '" + similar + "'?"
-					}
-
-					findings = append(findings, f)
-					continue
-				}
-			}
+			checkLicense(valSlice, name, &findings)
 		case "provides":
-			mustBeArray(val, name, &findings)
+			checkPkgList(val, name, &findings)
 		case "conflicts":
-			mustBeArray(val, name, &findings)
+			checkPkgList(val, name, &findings)
 		case "deps":
-			mustBeArray(val, name, &findings)
+			checkPkgList(val, name, &findings)
 		case "build_deps":
-			mustBeArray(val, name, &findings)
+			checkPkgList(val, name, &findings)
 		case "replaces":
-			mustBeArray(val, name, &findings)
+			checkPkgList(val, name, &findings)
 		case "sources":
 			valSlice, ok := mustBeArray(val, name, &findings)
 			if !ok {
@@ -207,9 +322,12 @@ func AnalyzeScript(r *interp.Runner, fl *syntax.File) ([]Finding, error) {
 						Index:    i,
 						ItemName: name,
 						Msg:      "The %s must be a valid URL",
+						Rule:     RuleURL,
 					})
 					continue
 				}
+				checkSourcePolicy(u, name, i, &findings)
+
 				query := u.Query()
 
 				var validParams []string
@@ -231,7 +349,8 @@ func AnalyzeScript(r *interp.Runner, fl *syntax.File) ([]Finding, error) {
 							ItemType: "element",
 							ItemName: name,
 							Index:    i,
-							Msg:      "The %s contains an invalid parameter name '~" + paramName + "'",
+							Msg:      "The %s contains an invalid parameter name '~" + escapeMsg(paramName) + "'",
+							Rule:     RuleSourceParams,
 						})
 						continue
 					}
@@ -250,11 +369,22 @@ func AnalyzeScript(r *interp.Runner, fl *syntax.File) ([]Finding, error) {
 					ItemType: "array",
 					ItemName: name,
 					Msg:      "The %s is not the same size as its corresponding sources array",
+					Rule:     RuleChecksums,
 				})
 			}
 
 			for i, val := range valSlice {
 				if strings.EqualFold(val, "SKIP") {
+					// Only git sources can't have a checksum
+					if i < len(srcs.List) && !strings.HasPrefix(srcs.List[i], "git+") {
+						findings = append(findings, Finding{
+							ItemType: "element",
+							ItemName: name,
+							Index:    i,
+							Msg:      "The %s is SKIP, but its source isn't a git repository. Use the SHA256 checksum of the file instead.",
+							Rule:     RuleChecksumSkip,
+						})
+					}
 					continue
 				}
 
@@ -264,6 +394,7 @@ func AnalyzeScript(r *interp.Runner, fl *syntax.File) ([]Finding, error) {
 						ItemName: name,
 						Index:    i,
 						Msg:      "The %s contains an invalid SHA256 checksum. SHA256 hashes must be 64 characters in length.",
+						Rule:     RuleChecksums,
 					})
 					continue
 				}
@@ -275,32 +406,42 @@ func AnalyzeScript(r *interp.Runner, fl *syntax.File) ([]Finding, error) {
 						ItemName: name,
 						Index:    i,
 						Msg:      "The %s contains an invalid SHA256 checksum. SHA256 hashes must be valid hexadecimal.",
+						Rule:     RuleChecksums,
 					})
 					continue
 				}
 			}
-		case "backup":
+		case "opt_deps":
+			checkOptDeps(val, name, &findings)
+		case "build_vars":
+			checkBuildVars(val, name, &findings)
+		case "desc":
+			checkDesc(val, name, &findings)
+		case "auto_req", "auto_prov":
+			checkBool(val, name, &findings)
+		case "group":
+			checkGroup(val, name, &findings)
+		case "section":
+			checkSection(val, name, &findings)
+		case "priority":
+			checkPriority(val, name, &findings)
+		case "firmware":
 			mustBeArray(val, name, &findings)
+		case "backup":
+			checkBackup(val, name, &findings)
 		case "scripts":
-			mustBeMap(val, name, &findings)
+			checkScripts(val, name, res.FS, &findings)
 		}
 	}
 
-	lns := FindLines(fl)
-	for i, finding := range findings {
-		if finding.ItemType == "function" {
-			ln, ok := lns.Funcs[finding.ItemName]
-			if ok {
-				findings[i].Line = ln
-			}
-		} else {
-			ln, ok := lns.Vars[finding.ItemName]
-			if ok {
-				findings[i].Line = ln
-			}
-		}
+	// Packages with a version function get their version from
+	// their git sources, so the version variable isn't in them
+	if _, ok := r.Funcs["version"]; !ok {
+		findings = append(findings, versionSourcesFindings(ver, r.Vars)...)
 	}
 
+	setPositions(findings, fl)
+
 	return findings, nil
 }
 
@@ -311,6 +452,7 @@ func mustBeStr(val any, name string, findings *[]Finding) (string, bool) {
 			ItemType: "variable",
 			ItemName: name,
 			Msg:      "The %s must be a string",
+			Rule:     RuleType,
 		})
 	}
 	return valStr, ok
@@ -323,6 +465,7 @@ func mustBeArray(val any, name string, findings *[]Finding) ([]string, bool) {
 			ItemType: "variable",
 			ItemName: name,
 			Msg:      "The %s must be an array",
+			Rule:     RuleType,
 		})
 	}
 	return valSlice, ok
@@ -335,6 +478,7 @@ func mustBeMap(val any, name string, findings *[]Finding) (map[string]string, bo
 			ItemType: "variable",
 			ItemName: name,
 			Msg:      "The %s must be a map",
+			Rule:     RuleType,
 		})
 	}
 	return valMap, ok
@@ -358,29 +502,3 @@ func isNumeric(s string) bool {
 	})
 	return index == -1
 }
-
-type Lines struct {
-	Vars  map[string]uint
-	Funcs map[string]uint
-}
-
-func FindLines(fl *syntax.File) Lines {
-	out := Lines{map[string]uint{}, map[string]uint{}}
-
-	for _, stmt := range fl.Stmts {
-		switch cmd := stmt.Cmd.(type) {
-		case *syntax.CallExpr:
-			if len(cmd.Assigns) == 0 {
-				continue
-			}
-
-			name := cmd.Assigns[0].Name.Value
-			line := cmd.Assigns[0].Pos().Line()
-			out.Vars[name] = line
-		case *syntax.FuncDecl:
-			out.Funcs[cmd.Name.Value] = cmd.Pos().Line()
-		}
-	}
-
-	return out
-}
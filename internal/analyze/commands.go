@@ -0,0 +1,80 @@
+package analyze
+
+import (
+	"strings"
+
+	"go.arsenm.dev/lure-repo-bot/internal/sandbox"
+	"golang.org/x/exp/slices"
+)
+
+// commandFindings generates findings for the external
+// commands that a script ran while it was being evaluated
+func commandFindings(cmds []sandbox.Command) []Finding {
+	if len(cmds) == 0 {
+		return nil
+	}
+
+	var names []string
+	for _, cmd := range cmds {
+		if !slices.Contains(names, cmd.Args[0]) {
+			names = append(names, cmd.Args[0])
+		}
+	}
+	invoked := "Commands invoked during evaluation: `" + strings.Join(names, "`, `") + "`"
+
+	var (
+		findings []Finding
+		seen     []uint
+	)
+	for _, cmd := range cmds {
+		// Commands in loops run many times, but
+		// they should only be reported once
+		if slices.Contains(seen, cmd.Pos.Offset()) {
+			continue
+		}
+		seen = append(seen, cmd.Pos.Offset())
+
+		f := Finding{
+			ItemType: "command",
+			ItemName: strings.Join(cmd.Args, " "),
+			Rule:     RuleCommands,
+			Severity: SeverityWarning,
+		}
+
+		if cmd.InSubst {
+			f.Msg = "The %s is run in a command substitution while LURE reads the script, so it runs every time the script is read and may behave differently on users' machines"
+		} else {
+			f.Msg = "The %s is run while LURE reads the script, so it runs every time the script is read and may behave differently on users' machines"
+		}
+		f.ExtraMsg = invoked
+		f.SetRange(Range{cmd.Pos, cmd.End})
+
+		findings = append(findings, f)
+	}
+
+	return findings
+}
+
+// accessFindings generates findings for the files that a script
+// wasn't allowed to access while it was being evaluated
+func accessFindings(accesses []sandbox.Access) []Finding {
+	var findings []Finding
+	for _, access := range accesses {
+		f := Finding{
+			ItemType: "file",
+			ItemName: access.Path,
+			Rule:     RuleFileAccess,
+			Severity: SeverityWarning,
+		}
+
+		if access.Write {
+			f.Msg = "The %s is written while LURE reads the script. Scripts must not modify any files outside of functions."
+		} else {
+			f.Msg = "The %s is outside of the package directory, so it may not exist or may be different on users' machines"
+		}
+		f.SetRange(Range{Start: access.Pos})
+
+		findings = append(findings, f)
+	}
+	return findings
+}
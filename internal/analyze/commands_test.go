@@ -0,0 +1,92 @@
+package analyze
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.arsenm.dev/lure-repo-bot/internal/sandbox"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+func TestCommandFindings(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		items    []string
+		lines    []uint
+		inSubst  []bool
+		extraMsg string
+	}{
+		{
+			name:     "none",
+			script:   "name=x\n",
+			extraMsg: "",
+		},
+		{
+			name:     "top level",
+			script:   "git fetch\n",
+			items:    []string{"git fetch"},
+			lines:    []uint{1},
+			inSubst:  []bool{false},
+			extraMsg: "Commands invoked during evaluation: `git`",
+		},
+		{
+			name:     "substitution",
+			script:   "name=x\nversion=$(git describe)\n",
+			items:    []string{"git describe"},
+			lines:    []uint{2},
+			inSubst:  []bool{true},
+			extraMsg: "Commands invoked during evaluation: `git`",
+		},
+		{
+			name:     "loop",
+			script:   "for i in 1 2 3; do\n\tcurl $i\ndone\n",
+			items:    []string{"curl 1"},
+			lines:    []uint{2},
+			inSubst:  []bool{false},
+			extraMsg: "Commands invoked during evaluation: `curl`",
+		},
+		{
+			name:     "several",
+			script:   "a=$(uname -m)\nb=$(uname -r)\nc=$(date)\n",
+			items:    []string{"uname -m", "uname -r", "date"},
+			lines:    []uint{1, 2, 3},
+			inSubst:  []bool{true, true, true},
+			extraMsg: "Commands invoked during evaluation: `uname`, `date`",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fl, err := syntax.NewParser().Parse(strings.NewReader(tt.script), "lure.sh")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := sandbox.Evaluate(context.Background(), fl, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			findings := commandFindings(res.Commands)
+			if len(findings) != len(tt.items) {
+				t.Fatalf("got %d findings, wanted %d", len(findings), len(tt.items))
+			}
+
+			for i, f := range findings {
+				if f.ItemName != tt.items[i] || f.StartLine != tt.lines[i] {
+					t.Errorf("got %q on line %d, wanted %q on line %d", f.ItemName, f.StartLine, tt.items[i], tt.lines[i])
+				}
+
+				if inSubst := strings.Contains(f.Msg, "command substitution"); inSubst != tt.inSubst[i] {
+					t.Errorf("%s: got message %q, wanted it in a command substitution = %v", f.ItemName, f.Msg, tt.inSubst[i])
+				}
+
+				if f.ExtraMsg != tt.extraMsg {
+					t.Errorf("got extra message %q, wanted %q", f.ExtraMsg, tt.extraMsg)
+				}
+			}
+		})
+	}
+}
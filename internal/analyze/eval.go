@@ -0,0 +1,45 @@
+package analyze
+
+import (
+	"errors"
+	"fmt"
+
+	"go.arsenm.dev/lure-repo-bot/internal/sandbox"
+)
+
+// EvalFinding converts an error returned by sandbox.Evaluate into a
+// finding. If the error wasn't caused by the script exceeding
+// one of the sandbox's limits, it returns false.
+func EvalFinding(err error) (Finding, bool) {
+	f := Finding{
+		ItemType: "script",
+		ItemName: "lure.sh",
+		Rule:     RuleEvaluation,
+	}
+
+	var uerr *sandbox.UnsupportedError
+	switch {
+	case errors.As(err, &uerr):
+		f.Msg = "The %s uses " + escapeMsg(uerr.Feature) + ", which can't be evaluated"
+		f.ExtraMsg = "LURE reads scripts using a shell interpreter that doesn't support this, so it can only be used in functions that don't run while the script is read."
+		f.SetRange(Range{Start: uerr.Pos})
+		return f, true
+	case errors.Is(err, sandbox.ErrTimeout):
+		f.Msg = fmt.Sprintf("The %%s did not finish evaluating within %d seconds", int(sandbox.DefaultConfig.Timeout.Seconds()))
+	case errors.Is(err, sandbox.ErrStepLimit):
+		f.Msg = "The %s ran too many commands while it was being evaluated"
+	case errors.Is(err, sandbox.ErrOutputLimit):
+		f.Msg = "The %s wrote too much output while it was being evaluated"
+	case errors.Is(err, sandbox.ErrVarLimit):
+		f.Msg = "The %s created a variable that is too large while it was being evaluated"
+	case errors.Is(err, sandbox.ErrDepthLimit):
+		f.Msg = "The %s nested too many function calls while it was being evaluated"
+	case errors.Is(err, sandbox.ErrBraceLimit):
+		f.Msg = "The %s contains brace expansions that are too large, such as {1..1000000}"
+	default:
+		return Finding{}, false
+	}
+
+	f.ExtraMsg = "LURE evaluates the whole script to read its variables, so code outside of functions must finish quickly."
+	return f, true
+}
@@ -0,0 +1,335 @@
+package analyze
+
+import (
+	"strings"
+
+	"golang.org/x/exp/slices"
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// checkedFuncs are the functions whose bodies are analyzed
+var checkedFuncs = []string{"prepare", "build", "package"}
+
+// lureVars are the variables that LURE provides to scripts
+var lureVars = []string{
+	"srcdir", "pkgdir", "scriptdir",
+	"ARCH", "NCPU",
+	"DISTRO_NAME", "DISTRO_PRETTY_NAME", "DISTRO_ID",
+	"DISTRO_VERSION_ID", "DISTRO_ID_LIKE",
+}
+
+// funcFindings statically analyzes the bodies of the
+// functions LURE runs while building a package
+func funcFindings(vars map[string]expand.Variable, fl *syntax.File) []Finding {
+	var findings []Finding
+	defined := definedVars(vars, fl)
+
+	for _, stmt := range fl.Stmts {
+		fn, ok := stmt.Cmd.(*syntax.FuncDecl)
+		if !ok || !slices.Contains(checkedFuncs, fn.Name.Value) {
+			continue
+		}
+
+		fc := funcChecker{
+			name:    fn.Name.Value,
+			defined: defined,
+			checked: map[*syntax.CallExpr]bool{},
+		}
+		syntax.Walk(fn.Body, fc.walk)
+		findings = append(findings, fc.findings...)
+	}
+
+	return findings
+}
+
+type funcChecker struct {
+	name     string
+	defined  []string
+	findings []Finding
+	// checked contains the cd calls that are properly checked
+	// with || exit, || return, &&, or by using them as a condition
+	checked map[*syntax.CallExpr]bool
+}
+
+func (fc *funcChecker) walk(node syntax.Node) bool {
+	switch node := node.(type) {
+	case *syntax.BinaryCmd:
+		// The left side of a binary command is visited first,
+		// so the cd calls can be marked before they're checked
+		if call, ok := node.X.Cmd.(*syntax.CallExpr); ok && isCall(call, "cd") {
+			if node.Op == syntax.AndStmt || isExit(node.Y) {
+				fc.checked[call] = true
+			}
+		}
+	case *syntax.IfClause:
+		fc.checkCond(node.Cond)
+	case *syntax.WhileClause:
+		fc.checkCond(node.Cond)
+	case *syntax.CallExpr:
+		fc.checkCall(node)
+	case *syntax.Lit:
+		if strings.Contains(node.Value, "/usr/local") {
+			fc.add(node, RuleUsrLocal, SeverityWarning,
+				"The %s uses a hardcoded /usr/local path. Packages should install files under /usr instead.")
+		}
+	}
+	return true
+}
+
+// checkCond marks a cd call as checked if it determines the result
+// of an if, while, or until condition, like `if cd dir; then`
+func (fc *funcChecker) checkCond(cond []*syntax.Stmt) {
+	if len(cond) == 0 {
+		return
+	}
+
+	if call, ok := cond[len(cond)-1].Cmd.(*syntax.CallExpr); ok && isCall(call, "cd") {
+		fc.checked[call] = true
+	}
+}
+
+func (fc *funcChecker) checkCall(call *syntax.CallExpr) {
+	if len(call.Args) == 0 {
+		return
+	}
+
+	args := call.Args[1:]
+	switch call.Args[0].Lit() {
+	case "sudo":
+		fc.add(call, RuleSudo, SeverityError,
+			"The %s uses sudo. LURE runs the build functions as the user and installs the package itself, so sudo is never needed.")
+	case "cd":
+		if !fc.checked[call] {
+			fc.addFix(call, RuleCdExit, SeverityWarning,
+				"The %s uses cd without checking whether it failed. Use `cd dir || exit 1` so that the commands after it don't run in the wrong directory.",
+				&Fix{
+					Title: "Add || exit 1",
+					Edits: []Edit{{Range{call.End(), call.End()}, " || exit 1"}},
+				})
+		}
+	case "rm":
+		fc.checkRm(args)
+	case "curl", "wget":
+		fc.checkNetwork(call)
+	case "git":
+		if len(args) > 0 && slices.Contains([]string{"clone", "fetch", "pull"}, args[0].Lit()) {
+			fc.checkNetwork(call)
+		}
+	case "make", "ninja":
+		fc.checkDestdir(call)
+	}
+
+	if fc.name != "package" {
+		return
+	}
+
+	switch call.Args[0].Lit() {
+	case "install", "cp", "mv", "ln":
+		if dest := destArg(args); dest != nil && isAbsLit(dest) {
+			fc.add(dest, RulePkgdir, SeverityError,
+				"The %s installs files to an absolute path outside of \"$pkgdir\". Prefix the destination with \"$pkgdir\".")
+		}
+	case "mkdir", "touch":
+		for _, arg := range args {
+			if isAbsLit(arg) {
+				fc.add(arg, RulePkgdir, SeverityError,
+					"The %s creates files at an absolute path outside of \"$pkgdir\". Prefix the path with \"$pkgdir\".")
+			}
+		}
+	}
+}
+
+// checkRm looks for commands like rm -rf "$var"/ that
+// will delete everything if var is empty or unset
+func (fc *funcChecker) checkRm(args []*syntax.Word) {
+	recursive := false
+	for _, arg := range args {
+		lit := arg.Lit()
+		if strings.HasPrefix(lit, "-") && !strings.HasPrefix(lit, "--") && strings.ContainsAny(lit, "rR") {
+			recursive = true
+		} else if lit == "--recursive" {
+			recursive = true
+		}
+	}
+
+	if !recursive {
+		return
+	}
+
+	for _, arg := range args {
+		pe, ok := firstPart(arg).(*syntax.ParamExp)
+		if !ok || pe.Param == nil || slices.Contains(fc.defined, pe.Param.Value) {
+			continue
+		}
+
+		// Positional and special parameters like $1 and $@
+		if !syntax.ValidName(pe.Param.Value) {
+			continue
+		}
+
+		// ${var:?} and ${var?} make the shell exit if var is unset
+		if pe.Exp != nil && (pe.Exp.Op == syntax.ErrorUnset || pe.Exp.Op == syntax.ErrorUnsetOrNull) {
+			continue
+		}
+
+		fc.add(arg, RuleRmUnset, SeverityError,
+			"The %s recursively deletes a path starting with $"+pe.Param.Value+", which is never defined. If it's empty, this will delete files outside of the package.")
+	}
+}
+
+func (fc *funcChecker) checkNetwork(call *syntax.CallExpr) {
+	if fc.name != "package" {
+		return
+	}
+
+	fc.add(call, RulePackageNetwork, SeverityError,
+		"The %s accesses the network. Anything that needs to be downloaded should be added to sources instead.")
+}
+
+// checkDestdir makes sure make install and
+// ninja install are run with DESTDIR set
+func (fc *funcChecker) checkDestdir(call *syntax.CallExpr) {
+	if fc.name != "package" {
+		return
+	}
+
+	install := false
+	for _, arg := range call.Args[1:] {
+		lit := arg.Lit()
+		if lit == "install" {
+			install = true
+		} else if strings.HasPrefix(lit, "DESTDIR=") || strings.HasPrefix(firstLit(arg), "DESTDIR=") {
+			return
+		}
+	}
+
+	for _, assign := range call.Assigns {
+		if assign.Name.Value == "DESTDIR" {
+			return
+		}
+	}
+
+	if install {
+		fc.add(call, RulePkgdir, SeverityError,
+			"The %s runs an install target without setting DESTDIR, so files will be installed outside of \"$pkgdir\". Use DESTDIR=\"$pkgdir\".")
+	}
+}
+
+func (fc *funcChecker) add(node syntax.Node, rule string, severity Severity, msg string) {
+	fc.addFix(node, rule, severity, msg, nil)
+}
+
+func (fc *funcChecker) addFix(node syntax.Node, rule string, severity Severity, msg string, fix *Fix) {
+	f := Finding{
+		ItemType: "function",
+		ItemName: fc.name,
+		Msg:      msg,
+		Rule:     rule,
+		Severity: severity,
+		Fix:      fix,
+	}
+	f.SetRange(NodeRange(node))
+	fc.findings = append(fc.findings, f)
+}
+
+// definedVars returns the names of all the variables that are
+// provided by LURE, set while evaluating the script, or assigned
+// anywhere in it
+func definedVars(vars map[string]expand.Variable, fl *syntax.File) []string {
+	out := slices.Clone(lureVars)
+	for name := range vars {
+		out = append(out, name)
+	}
+
+	syntax.Walk(fl, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.Assign:
+			if node.Name != nil {
+				out = append(out, node.Name.Value)
+			}
+		case *syntax.WordIter:
+			out = append(out, node.Name.Value)
+		case *syntax.CallExpr:
+			// read and getopts assign to the variables given as arguments
+			if isCall(node, "read") || isCall(node, "getopts") {
+				for _, arg := range node.Args[1:] {
+					if lit := arg.Lit(); lit != "" && !strings.HasPrefix(lit, "-") {
+						out = append(out, lit)
+					}
+				}
+			}
+		}
+		return true
+	})
+
+	return out
+}
+
+// destArg returns the destination argument for
+// commands like cp and install
+func destArg(args []*syntax.Word) *syntax.Word {
+	for i, arg := range args {
+		lit := arg.Lit()
+		if lit == "-t" && i+1 < len(args) {
+			return args[i+1]
+		} else if strings.HasPrefix(lit, "--target-directory=") {
+			return nil
+		}
+	}
+
+	if len(args) < 2 {
+		return nil
+	}
+	return args[len(args)-1]
+}
+
+func isCall(call *syntax.CallExpr, name string) bool {
+	return len(call.Args) > 0 && call.Args[0].Lit() == name
+}
+
+// isExit checks if stmt is an exit or return command, or
+// a block or subshell that ends with one, like { echo; exit 1; }
+func isExit(stmt *syntax.Stmt) bool {
+	var stmts []*syntax.Stmt
+	switch cmd := stmt.Cmd.(type) {
+	case *syntax.CallExpr:
+		return isCall(cmd, "exit") || isCall(cmd, "return")
+	case *syntax.Block:
+		stmts = cmd.Stmts
+	case *syntax.Subshell:
+		stmts = cmd.Stmts
+	}
+	return len(stmts) > 0 && isExit(stmts[len(stmts)-1])
+}
+
+// firstPart returns the first part of w, looking inside double quotes
+func firstPart(w *syntax.Word) syntax.WordPart {
+	if len(w.Parts) == 0 {
+		return nil
+	}
+
+	part := w.Parts[0]
+	if dq, ok := part.(*syntax.DblQuoted); ok && len(dq.Parts) > 0 {
+		return dq.Parts[0]
+	}
+	return part
+}
+
+// firstLit returns the literal value of the first part of w,
+// or an empty string if it's not a literal
+func firstLit(w *syntax.Word) string {
+	switch part := firstPart(w).(type) {
+	case *syntax.Lit:
+		return part.Value
+	case *syntax.SglQuoted:
+		return part.Value
+	default:
+		return ""
+	}
+}
+
+// isAbsLit checks whether w starts with a literal absolute path
+func isAbsLit(w *syntax.Word) bool {
+	return strings.HasPrefix(firstLit(w), "/")
+}
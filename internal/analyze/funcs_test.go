@@ -0,0 +1,140 @@
+package analyze
+
+import (
+	"strings"
+	"testing"
+
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+func TestCdExit(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		wanted int
+	}{
+		{"unchecked", "cd x", 1},
+		{"or exit", "cd x || exit 1", 0},
+		{"or return", "cd x || return 1", 0},
+		{"and", "cd x && make", 0},
+		{"or echo", "cd x || echo failed", 1},
+		{"if condition", "if cd x; then make; fi", 0},
+		{"negated if condition", "if ! cd x; then exit 1; fi", 0},
+		{"elif condition", "if false; then :; elif cd x; then make; fi", 0},
+		{"while condition", "while cd x; do make; done", 0},
+		{"until condition", "until cd x; do sleep 1; done", 0},
+		{"not last in condition", "if cd x; true; then make; fi", 1},
+		{"in if body", "if true; then cd x; fi", 1},
+		{"or block", "cd x || { echo no; exit 1; }", 0},
+		{"or subshell", "cd x || (echo no; exit 1)", 0},
+		{"or block without exit", "cd x || { echo no; }", 1},
+		{"or block not ending with exit", "cd x || { exit 1; echo no; }", 1},
+		{"nested block", "cd x || { echo no; { exit 1; }; }", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "build() {\n" + tt.body + "\n}\n"
+			fl, err := syntax.NewParser().Parse(strings.NewReader(src), "lure.sh")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			count := 0
+			for _, f := range funcFindings(nil, fl) {
+				if f.Rule == RuleCdExit {
+					count++
+				}
+			}
+
+			if count != tt.wanted {
+				t.Errorf("got %d cd-exit findings, wanted %d", count, tt.wanted)
+			}
+		})
+	}
+}
+
+func TestFuncFindings(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+		// fn is the function the body is in
+		fn   string
+		body string
+		// vars are the variables set while evaluating the script
+		vars   []string
+		wanted int
+	}{
+		{"sudo", RuleSudo, "build", "sudo make install", nil, 1},
+		{"sudo in prepare", RuleSudo, "prepare", "sudo true", nil, 1},
+		{"sudo as argument", RuleSudo, "build", "echo sudo", nil, 0},
+		{"sudo outside checked function", RuleSudo, "helper", "sudo true", nil, 0},
+
+		{"install absolute", RulePkgdir, "package", "install -Dm755 foo /usr/bin/foo", nil, 1},
+		{"install pkgdir", RulePkgdir, "package", `install -Dm755 foo "$pkgdir"/usr/bin/foo`, nil, 0},
+		{"install target directory", RulePkgdir, "package", "install -Dm644 -t /usr/share/foo a b", nil, 1},
+		{"install pkgdir target directory", RulePkgdir, "package", `install -Dm644 -t "$pkgdir/usr/share/foo" a b`, nil, 0},
+		{"install in build", RulePkgdir, "build", "install -Dm755 foo /usr/bin/foo", nil, 0},
+		{"cp absolute", RulePkgdir, "package", "cp -r foo /opt/foo", nil, 1},
+		{"cp absolute source", RulePkgdir, "package", `cp /etc/foo.conf "$pkgdir"/etc/`, nil, 0},
+		{"mkdir absolute", RulePkgdir, "package", `mkdir -p "$pkgdir"/usr /usr/share/foo`, nil, 1},
+		{"mkdir pkgdir", RulePkgdir, "package", `mkdir -p "$pkgdir/usr/share/foo"`, nil, 0},
+		{"make install", RulePkgdir, "package", "make install", nil, 1},
+		{"make install with DESTDIR", RulePkgdir, "package", `make DESTDIR="$pkgdir" install`, nil, 0},
+		{"make install with quoted DESTDIR", RulePkgdir, "package", `make "DESTDIR=$pkgdir" install`, nil, 0},
+		{"make without install", RulePkgdir, "package", "make -j4", nil, 0},
+		{"make install in build", RulePkgdir, "build", "make install", nil, 0},
+		{"ninja install", RulePkgdir, "package", "ninja -C build install", nil, 1},
+		{"ninja install with DESTDIR", RulePkgdir, "package", `DESTDIR="$pkgdir" ninja -C build install`, nil, 0},
+
+		{"usr-local prefix", RuleUsrLocal, "build", "./configure --prefix=/usr/local", nil, 1},
+		{"usr-local quoted", RuleUsrLocal, "package", `install -Dm755 foo "$pkgdir/usr/local/bin/foo"`, nil, 1},
+		{"usr prefix", RuleUsrLocal, "build", "./configure --prefix=/usr", nil, 0},
+
+		{"rm unset", RuleRmUnset, "package", `rm -rf "$foo"/`, nil, 1},
+		{"rm long option", RuleRmUnset, "package", `rm --recursive "$foo/bar"`, nil, 1},
+		{"rm braces", RuleRmUnset, "package", `rm -r "${foo}"/bar`, nil, 1},
+		{"rm not recursive", RuleRmUnset, "package", `rm -f "$foo"/bar`, nil, 0},
+		{"rm lure variable", RuleRmUnset, "package", `rm -rf "$srcdir"/build`, nil, 0},
+		{"rm assigned", RuleRmUnset, "package", "foo=build\n" + `rm -rf "$foo"/`, nil, 0},
+		{"rm evaluated", RuleRmUnset, "package", `rm -rf "$foo"/`, []string{"foo"}, 0},
+		{"rm error if unset", RuleRmUnset, "package", `rm -rf "${foo:?}"/`, nil, 0},
+		{"rm positional", RuleRmUnset, "package", `rm -rf "$1"/`, nil, 0},
+		{"rm loop variable", RuleRmUnset, "package", `for f in a b; do rm -rf "$f"; done`, nil, 0},
+		{"rm read variable", RuleRmUnset, "package", "read -r dir < list\n" + `rm -rf "$dir"/`, nil, 0},
+
+		{"curl", RulePackageNetwork, "package", "curl -LO https://example.com/foo", nil, 1},
+		{"wget", RulePackageNetwork, "package", "wget https://example.com/foo", nil, 1},
+		{"git clone", RulePackageNetwork, "package", "git clone https://example.com/foo.git", nil, 1},
+		{"git pull", RulePackageNetwork, "package", "git pull", nil, 1},
+		{"git status", RulePackageNetwork, "package", "git status", nil, 0},
+		{"curl in build", RulePackageNetwork, "build", "curl -LO https://example.com/foo", nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := tt.fn + "() {\n" + tt.body + "\n}\n"
+			fl, err := syntax.NewParser().Parse(strings.NewReader(src), "lure.sh")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			vars := map[string]expand.Variable{}
+			for _, name := range tt.vars {
+				vars[name] = expand.Variable{Kind: expand.String, Str: "x"}
+			}
+
+			count := 0
+			for _, f := range funcFindings(vars, fl) {
+				if f.Rule == tt.rule {
+					count++
+				}
+			}
+
+			if count != tt.wanted {
+				t.Errorf("got %d %s findings, wanted %d", count, tt.rule, tt.wanted)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package analyze
+
+import (
+	"strconv"
+	"strings"
+
+	"go.arsenm.dev/lure-repo-bot/internal/spdx"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// checkLicense checks that every element of the license
+// array is a valid SPDX license expression
+func checkLicense(valSlice []string, name string, findings *[]Finding) {
+	for i, val := range valSlice {
+		if strings.Contains(strings.ToLower(val), "custom") {
+			continue
+		}
+
+		add := func(msg, extraMsg string) {
+			*findings = append(*findings, Finding{
+				ItemType: "element",
+				ItemName: name,
+				Index:    i,
+				Msg:      msg,
+				Rule:     RuleLicense,
+				ExtraMsg: extraMsg,
+			})
+		}
+
+		expr, err := spdx.ParseExpr(val)
+		if err != nil {
+			add("The %s isn't a valid SPDX license expression: '"+escapeMsg(val)+"'.", err.Error())
+			continue
+		}
+
+		for _, license := range spdx.ExprLicenses(expr) {
+			// LicenseRefs are defined by the package,
+			// so they can't be checked
+			if !license.IsRef() && spdx.Licenses.License(license.ID) == nil {
+				msg := "The %s contains an invalid SPDX license identifier: '" + escapeMsg(license.ID) + "'."
+				if similar := spdx.FindSimilarLicense(license.ID); similar != "" {
+					msg += " Did you mean '" + similar + "'?"
+				}
+				add(msg, "A list of SPDX license identifiers can be found at https://spdx.org/licenses/.")
+			}
+
+			if license.Exception != "" && spdx.Exceptions.Exception(license.Exception) == nil {
+				msg := "The %s contains an invalid SPDX license exception: '" + license.Exception + "'."
+				if similar := spdx.FindSimilarException(license.Exception); similar != "" {
+					msg += " Did you mean '" + similar + "'?"
+				}
+				add(msg, "A list of SPDX license exceptions can be found at https://spdx.org/licenses/exceptions-index.html.")
+			}
+		}
+	}
+}
+
+// deprecatedLicenseFindings looks for deprecated license IDs in the
+// assignments to license outside of functions, including the ones in
+// if statements and declare. It works on the syntax tree instead
+// of the evaluated values, so that each finding can have a fix that
+// replaces just the deprecated ID.
+func deprecatedLicenseFindings(fl *syntax.File) []Finding {
+	var findings []Finding
+
+	indexes := indexTracker{}
+
+	syntax.Walk(fl, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.FuncDecl:
+			return false
+		case *syntax.Assign:
+			if node.Name == nil {
+				return true
+			}
+
+			name := node.Name.Value
+			if base, _, _ := ParseVarName(name); base != "license" {
+				return true
+			}
+
+			// The elements are tracked even if license is a
+			// string, which is reported by checkLicense
+			elems := indexes.elems(node)
+			if node.Array == nil && node.Index == nil {
+				return true
+			}
+
+			for _, elem := range elems {
+				if i, err := strconv.Atoi(elem.key); err == nil {
+					findings = append(findings, deprecatedElemFindings(elem.value, name, i)...)
+				}
+			}
+		}
+		return true
+	})
+
+	return findings
+}
+
+// deprecatedElemFindings checks a single element of a license array
+func deprecatedElemFindings(w *syntax.Word, name string, i int) []Finding {
+	val, start, ok := wordText(w)
+	if !ok || strings.Contains(strings.ToLower(val), "custom") {
+		return nil
+	}
+
+	expr, err := spdx.ParseExpr(val)
+	if err != nil {
+		// Invalid expressions are reported by checkLicense
+		return nil
+	}
+
+	var findings []Finding
+	for _, license := range spdx.ExprLicenses(expr) {
+		if !spdx.IsDeprecated(license) {
+			continue
+		}
+
+		old := val[license.Offset:license.End]
+		f := Finding{
+			ItemType: "element",
+			ItemName: name,
+			Index:    i,
+			Msg:      "The %s contains the deprecated SPDX license identifier '" + escapeMsg(old) + "'.",
+			Rule:     RuleDeprecatedLicense,
+			Severity: SeverityWarning,
+		}
+
+		rng := Range{offsetPos(start, val, license.Offset), offsetPos(start, val, license.End)}
+		if repl := spdx.Replacement(license); repl != nil {
+			f.Msg += " Use '" + escapeMsg(repl.String()) + "' instead."
+			f.Fix = &Fix{
+				Title: "Replace " + old + " with " + repl.String(),
+				Edits: []Edit{{rng, repl.String()}},
+			}
+
+			if repl.ID == license.ID+"-only" {
+				f.ExtraMsg = "If the package can be used under any later version of the license, use '" + license.ID + "-or-later' instead."
+			}
+		}
+
+		f.SetRange(rng)
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// wordText returns the value of a word that only contains
+// a literal or quoted literal, and the position where
+// the value starts in the script
+func wordText(w *syntax.Word) (string, syntax.Pos, bool) {
+	if len(w.Parts) != 1 {
+		return "", syntax.Pos{}, false
+	}
+
+	switch part := w.Parts[0].(type) {
+	case *syntax.Lit:
+		return part.Value, part.Pos(), true
+	case *syntax.SglQuoted:
+		if part.Dollar {
+			return "", syntax.Pos{}, false
+		}
+		return part.Value, offsetPos(part.Pos(), "'", 1), true
+	case *syntax.DblQuoted:
+		if len(part.Parts) != 1 {
+			return "", syntax.Pos{}, false
+		}
+		lit, ok := part.Parts[0].(*syntax.Lit)
+		if !ok {
+			return "", syntax.Pos{}, false
+		}
+		return lit.Value, lit.Pos(), true
+	default:
+		return "", syntax.Pos{}, false
+	}
+}
+
+// offsetPos returns the position of byte offset
+// off in text, which starts at start
+func offsetPos(start syntax.Pos, text string, off int) syntax.Pos {
+	line, col := start.Line(), start.Col()
+	for _, c := range []byte(text[:off]) {
+		if c == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return syntax.NewPos(start.Offset()+uint(off), line, col)
+}
@@ -0,0 +1,55 @@
+package analyze
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+func TestDeprecatedLicenseFindings(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{"top level", "license=('GPL-2.0')", []string{"license[0]"}},
+		{"expression", "license=('MIT OR GPL-3.0+')", []string{"license[0]"}},
+		{"not deprecated", "license=('GPL-2.0-only' 'MIT')", nil},
+		{"second element", "license=('MIT' \"LGPL-2.1\")", []string{"license[1]"}},
+		{"append", "license=('MIT')\nlicense+=('GPL-2.0')", []string{"license[1]"}},
+		{"explicit index", "license=([3]='GPL-2.0' 'GPL-3.0')", []string{"license[3]", "license[4]"}},
+		{"single element", "license[2]='GPL-2.0'", []string{"license[2]"}},
+		{"append after single element", "license[2]='MIT'\nlicense+=('GPL-2.0')", []string{"license[3]"}},
+		{"append after lower index", "license=('MIT' 'MIT')\nlicense[0]='MIT'\nlicense+=('GPL-2.0')", []string{"license[2]"}},
+		{"append after explicit indices", "license=([5]='MIT' [1]='MIT')\nlicense+=('GPL-2.0')", []string{"license[6]"}},
+		{"append after string", "license='MIT'\nlicense+=('GPL-2.0')", []string{"license[1]"}},
+		{"string", "license='GPL-2.0'", nil},
+		{"override", "license_ubuntu=('GPL-2.0')", []string{"license_ubuntu[0]"}},
+		{"if", "if true; then\n\tlicense=('GPL-2.0')\nfi", []string{"license[0]"}},
+		{"declare", "declare -a license=('GPL-2.0')", []string{"license[0]"}},
+		{"block", "{ license=('GPL-2.0'); }", []string{"license[0]"}},
+		{"function", "package() {\n\tlicense=('GPL-2.0')\n}", nil},
+		{"custom", "license=('custom:GPL-2.0')", nil},
+		{"expansion", "license=(\"$foo\")", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fl, err := syntax.NewParser().Parse(strings.NewReader(tt.script), "lure.sh")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, f := range deprecatedLicenseFindings(fl) {
+				got = append(got, fmt.Sprintf("%s[%v]", f.ItemName, f.Index))
+			}
+
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("got %v, wanted %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package analyze
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/exp/slices"
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// lintFindings checks the script for common shell mistakes
+// that aren't specific to LURE
+func lintFindings(vars map[string]expand.Variable, fl *syntax.File) []Finding {
+	var findings []Finding
+
+	syntax.Walk(fl, func(node syntax.Node) bool {
+		call, ok := node.(*syntax.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+
+		findings = append(findings, unquotedFindings(call)...)
+		findings = append(findings, testEqualsFindings(call)...)
+		return true
+	})
+
+	defined := definedVars(vars, fl)
+	used := usedVars(fl)
+	for _, stmt := range fl.Stmts {
+		fn, ok := stmt.Cmd.(*syntax.FuncDecl)
+		if !ok {
+			continue
+		}
+
+		findings = append(findings, unusedFindings(fn, used)...)
+		findings = append(findings, undefinedFindings(fn, defined)...)
+	}
+
+	return findings
+}
+
+// unquotedFindings looks for variables in command arguments
+// that aren't quoted, which causes them to be split on whitespace
+// and expanded as globs.
+func unquotedFindings(call *syntax.CallExpr) []Finding {
+	var findings []Finding
+	for _, arg := range call.Args[1:] {
+		for _, part := range arg.Parts {
+			pe, ok := part.(*syntax.ParamExp)
+			if !ok || pe.Length || pe.Param == nil || !syntax.ValidName(pe.Param.Value) {
+				continue
+			}
+
+			f := Finding{
+				ItemType: "variable",
+				ItemName: pe.Param.Value,
+				Msg:      "The %s is expanded without quotes, so it will be split on spaces and expanded as a glob. Use \"$" + pe.Param.Value + "\" instead.",
+				Rule:     RuleQuoteVars,
+				Severity: SeverityWarning,
+				Fix: &Fix{
+					Title: "Quote $" + pe.Param.Value,
+					Edits: []Edit{
+						{Range{pe.Pos(), pe.Pos()}, `"`},
+						{Range{pe.End(), pe.End()}, `"`},
+					},
+				},
+			}
+			f.SetRange(NodeRange(pe))
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// testEqualsFindings looks for == in [ and test commands,
+// which isn't supported by POSIX test
+func testEqualsFindings(call *syntax.CallExpr) []Finding {
+	name := call.Args[0].Lit()
+	if name != "[" && name != "test" {
+		return nil
+	}
+
+	var findings []Finding
+	for _, arg := range call.Args[1:] {
+		if arg.Lit() != "==" {
+			continue
+		}
+
+		f := Finding{
+			ItemType: "command",
+			ItemName: name,
+			Msg:      "The %s uses ==, which isn't portable. Use = instead, or use [[ ]].",
+			Rule:     RuleTestEquals,
+			Severity: SeverityWarning,
+			Fix: &Fix{
+				Title: "Replace == with =",
+				Edits: []Edit{{NodeRange(arg), "="}},
+			},
+		}
+		f.SetRange(NodeRange(arg))
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// unusedFindings looks for variables that are assigned
+// in fn, but never used anywhere in the script
+func unusedFindings(fn *syntax.FuncDecl, used []string) []Finding {
+	var findings []Finding
+	syntax.Walk(fn.Body, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.DeclClause:
+			// Exported variables are used by the commands that the
+			// function runs, so there's no way to tell if they're unused
+			if node.Variant.Value == "export" {
+				return false
+			}
+		case *syntax.CallExpr:
+			// Assignments before a command are
+			// environment variables for that command
+			if len(node.Args) > 0 {
+				return false
+			}
+		case *syntax.Assign:
+			if node.Name == nil || slices.Contains(used, node.Name.Value) {
+				return true
+			}
+
+			f := Finding{
+				ItemType: "variable",
+				ItemName: node.Name.Value,
+				Msg:      "The %s is assigned in the `" + escapeMsg(fn.Name.Value) + "` function, but it's never used",
+				Rule:     RuleUnusedVar,
+				Severity: SeverityWarning,
+			}
+			f.SetRange(NodeRange(node))
+			findings = append(findings, f)
+		}
+		return true
+	})
+	return findings
+}
+
+// undefinedFindings looks for variables that are used in fn,
+// but never assigned anywhere in the script or provided by LURE.
+func undefinedFindings(fn *syntax.FuncDecl, defined []string) []Finding {
+	var (
+		findings []Finding
+		reported []string
+	)
+
+	syntax.Walk(fn.Body, func(node syntax.Node) bool {
+		pe, ok := node.(*syntax.ParamExp)
+		if !ok || pe.Param == nil {
+			return true
+		}
+		name := pe.Param.Value
+
+		// All-uppercase variables are usually environment variables
+		// like CFLAGS and HOME, which can't be checked
+		if !syntax.ValidName(name) || !hasLower(name) {
+			return true
+		}
+
+		if slices.Contains(defined, name) || slices.Contains(reported, name) {
+			return true
+		}
+
+		// Expansions with a default value handle unset variables
+		if pe.Exp != nil && pe.Exp.Op != syntax.RemSmallPrefix && pe.Exp.Op != syntax.RemLargePrefix &&
+			pe.Exp.Op != syntax.RemSmallSuffix && pe.Exp.Op != syntax.RemLargeSuffix {
+			return true
+		}
+
+		reported = append(reported, name)
+		f := Finding{
+			ItemType: "variable",
+			ItemName: name,
+			Msg:      "The %s is used in the `" + escapeMsg(fn.Name.Value) + "` function, but it's never assigned",
+			Rule:     RuleUndefinedVar,
+			Severity: SeverityWarning,
+		}
+		f.SetRange(NodeRange(pe))
+		findings = append(findings, f)
+		return true
+	})
+
+	return findings
+}
+
+// usedVars returns the names of all the variables that
+// are referenced anywhere in the script
+func usedVars(fl *syntax.File) []string {
+	var out []string
+	syntax.Walk(fl, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.ParamExp:
+			if node.Param != nil {
+				out = append(out, node.Param.Value)
+			}
+		case *syntax.ArithmExp:
+			out = append(out, arithmVars(node.X)...)
+		case *syntax.ArithmCmd:
+			out = append(out, arithmVars(node.X)...)
+		}
+		return true
+	})
+	return out
+}
+
+// arithmVars returns the names of the variables used without
+// a $ in an arithmetic expression, such as x in $((x + 1))
+func arithmVars(expr syntax.ArithmExpr) []string {
+	var out []string
+	syntax.Walk(expr, func(node syntax.Node) bool {
+		if lit, ok := node.(*syntax.Lit); ok && syntax.ValidName(lit.Value) {
+			out = append(out, lit.Value)
+		}
+		return true
+	})
+	return out
+}
+
+func hasLower(s string) bool {
+	return strings.IndexFunc(s, unicode.IsLower) != -1
+}
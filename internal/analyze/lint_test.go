@@ -0,0 +1,206 @@
+package analyze
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// lintRule parses src and returns the lint findings for rule
+func lintRule(t *testing.T, src string, rule string) []Finding {
+	t.Helper()
+	fl, err := syntax.NewParser().Parse(strings.NewReader(src), "lure.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out []Finding
+	for _, f := range lintFindings(nil, fl) {
+		if f.Rule == rule {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// applyFixes applies the edits of every finding's fix to src
+func applyFixes(src string, findings []Finding) string {
+	var edits []Edit
+	for _, f := range findings {
+		if f.Fix != nil {
+			edits = append(edits, f.Fix.Edits...)
+		}
+	}
+
+	// Apply the edits from the end, so that
+	// the offsets of the others don't change
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].Range.Start.Offset() > edits[j].Range.Start.Offset()
+	})
+
+	for _, edit := range edits {
+		start, end := edit.Range.Start.Offset(), edit.Range.End.Offset()
+		src = src[:start] + edit.NewText + src[end:]
+	}
+	return src
+}
+
+func findingNames(findings []Finding) []string {
+	out := []string{}
+	for _, f := range findings {
+		out = append(out, f.ItemName)
+	}
+	return out
+}
+
+func TestUnquotedFindings(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		wanted int
+		fixed  string
+	}{
+		{"unquoted", "echo $x", 1, `echo "$x"`},
+		{"braces", "echo ${x}", 1, `echo "${x}"`},
+		{"two", "echo $x$y", 2, `echo "$x""$y"`},
+		{"prefix", "echo -I$x", 1, `echo -I"$x"`},
+		{"quoted", `echo "$x"`, 0, `echo "$x"`},
+		{"length", "echo ${#x}", 0, "echo ${#x}"},
+		{"positional", "echo $1", 0, "echo $1"},
+		{"special", "echo $?", 0, "echo $?"},
+		{"command name", "$x arg", 0, "$x arg"},
+		{"assignment", "x=$y", 0, "x=$y"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := lintRule(t, tt.src, RuleQuoteVars)
+			if len(findings) != tt.wanted {
+				t.Fatalf("got %d findings, wanted %d", len(findings), tt.wanted)
+			}
+
+			if fixed := applyFixes(tt.src, findings); fixed != tt.fixed {
+				t.Errorf("got fixed source %q, wanted %q", fixed, tt.fixed)
+			}
+		})
+	}
+}
+
+func TestTestEqualsFindings(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		wanted int
+		fixed  string
+	}{
+		{"bracket", `[ "$a" == b ]`, 1, `[ "$a" = b ]`},
+		{"test", "test a == b", 1, "test a = b"},
+		{"single equals", "[ a = b ]", 0, "[ a = b ]"},
+		{"double brackets", "[[ a == b ]]", 0, "[[ a == b ]]"},
+		{"other command", "echo ==", 0, "echo =="},
+		{"quoted", `[ a "==" b ]`, 0, `[ a "==" b ]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := lintRule(t, tt.src, RuleTestEquals)
+			if len(findings) != tt.wanted {
+				t.Fatalf("got %d findings, wanted %d", len(findings), tt.wanted)
+			}
+
+			if fixed := applyFixes(tt.src, findings); fixed != tt.fixed {
+				t.Errorf("got fixed source %q, wanted %q", fixed, tt.fixed)
+			}
+		})
+	}
+}
+
+func TestUnusedFindings(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		wanted []string
+	}{
+		{"unused", "package() { x=1; }", []string{"x"}},
+		{"used", `package() { x=1; echo "$x"; }`, []string{}},
+		{"used in another function", "package() { x=1; }\nbuild() { echo \"$x\"; }", []string{}},
+		{"used at top level", "y=$x\npackage() { x=1; }", []string{}},
+		{"arithmetic", "package() { x=1; echo $((x + 1)); }", []string{}},
+		{"arithmetic command", "package() { x=1; ((x++)); }", []string{}},
+		{"export", "package() { export x=1; }", []string{}},
+		{"environment", "package() { CFLAGS=-O2 make; }", []string{}},
+		{"local", "package() { local x=1; }", []string{"x"}},
+		{"several", "package() { x=1; y=2; z=$y; }", []string{"x", "z"}},
+		{"top level", "x=1", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := lintRule(t, tt.src, RuleUnusedVar)
+			if got := findingNames(findings); strings.Join(got, ",") != strings.Join(tt.wanted, ",") {
+				t.Errorf("got %v, wanted %v", got, tt.wanted)
+			}
+
+			for _, f := range findings {
+				if !strings.Contains(f.Message(false), "`package` function") {
+					t.Errorf("got message %q, wanted it to name the function", f.Message(false))
+				}
+			}
+		})
+	}
+}
+
+func TestUndefinedFindings(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		wanted []string
+	}{
+		{"undefined", `package() { echo "$foo"; }`, []string{"foo"}},
+		{"reported once", `package() { echo "$foo" "$foo"; }`, []string{"foo"}},
+		{"top level", "foo=1\npackage() { echo \"$foo\"; }", []string{}},
+		{"other function", "build() { foo=1; }\npackage() { echo \"$foo\"; }", []string{}},
+		{"default", `package() { echo "${foo:-bar}"; }`, []string{}},
+		{"alternative", `package() { echo "${foo:+bar}"; }`, []string{}},
+		{"remove suffix", `package() { echo "${foo%.tar}"; }`, []string{"foo"}},
+		{"remove prefix", `package() { echo "${foo##*/}"; }`, []string{"foo"}},
+		{"uppercase", `package() { echo "$CFLAGS $HOME"; }`, []string{}},
+		{"mixed case", `package() { echo "$Foo"; }`, []string{"Foo"}},
+		{"lure variable", `package() { echo "$srcdir" "$pkgdir"; }`, []string{}},
+		{"for loop", `package() { for f in *; do echo "$f"; done; }`, []string{}},
+		{"read", `package() { read -r line; echo "$line"; }`, []string{}},
+		{"positional", `package() { echo "$1" "$@"; }`, []string{}},
+		{"top level use", `echo "$foo"`, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := lintRule(t, tt.src, RuleUndefinedVar)
+			if got := findingNames(findings); strings.Join(got, ",") != strings.Join(tt.wanted, ",") {
+				t.Errorf("got %v, wanted %v", got, tt.wanted)
+			}
+
+			for _, f := range findings {
+				if !strings.Contains(f.Message(false), "`package` function") {
+					t.Errorf("got message %q, wanted it to name the function", f.Message(false))
+				}
+			}
+		})
+	}
+}
+
+func TestLintFunctionName(t *testing.T) {
+	src := "fo%do() { x=1; echo \"$y\"; }"
+	findings := append(lintRule(t, src, RuleUnusedVar), lintRule(t, src, RuleUndefinedVar)...)
+	if len(findings) != 2 {
+		t.Fatalf("got %d findings, wanted 2", len(findings))
+	}
+
+	for _, f := range findings {
+		if msg := f.Message(false); !strings.Contains(msg, "`fo%do` function") {
+			t.Errorf("got message %q, wanted it to contain the function's name", msg)
+		}
+	}
+}
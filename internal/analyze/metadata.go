@@ -0,0 +1,265 @@
+package analyze
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/exp/slices"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// debSections are the sections in the Debian archive. They can
+// be prefixed with an area, such as contrib/ or non-free/.
+var debSections = []string{
+	"admin", "cli-mono", "comm", "database", "debug", "devel", "doc",
+	"editors", "education", "electronics", "embedded", "fonts", "games",
+	"gnome", "gnu-r", "gnustep", "graphics", "hamradio", "haskell",
+	"httpd", "interpreters", "introspection", "java", "javascript", "kde",
+	"kernel", "libdevel", "libs", "lisp", "localization", "mail", "math",
+	"metapackages", "misc", "net", "news", "ocaml", "oldlibs", "otherosfs",
+	"perl", "php", "python", "ruby", "rust", "science", "shells", "sound",
+	"tasks", "tex", "text", "utils", "vcs", "video", "web", "x11", "xfce",
+	"zope",
+}
+
+// debPriorities are the allowed values of the priority variable
+var debPriorities = []string{"required", "important", "standard", "optional", "extra"}
+
+// boolValues are the values LURE accepts for boolean variables
+var boolValues = []string{"yes", "no", "true", "false", "1", "0"}
+
+// maxDescLen is the length after which descriptions
+// are cut off by most package managers
+const maxDescLen = 80
+
+func checkDesc(val any, name string, findings *[]Finding) {
+	if val == nil {
+		*findings = append(*findings, Finding{
+			ItemType: "variable",
+			ItemName: name,
+			Msg:      "The %s is empty",
+			Rule:     RuleDesc,
+			Severity: SeverityWarning,
+		})
+		return
+	}
+
+	valStr, ok := mustBeStr(val, name, findings)
+	if !ok {
+		return
+	}
+
+	if strings.Contains(valStr, "\n") {
+		*findings = append(*findings, Finding{
+			ItemType: "variable",
+			ItemName: name,
+			Msg:      "The %s must be a single line",
+			Rule:     RuleDesc,
+		})
+	} else if len(valStr) > maxDescLen {
+		*findings = append(*findings, Finding{
+			ItemType: "variable",
+			ItemName: name,
+			Msg:      "The %s is longer than 80 characters, so it may be cut off",
+			Rule:     RuleDesc,
+			Severity: SeverityWarning,
+		})
+	}
+}
+
+// checkPkgList checks that every element of a package
+// list, such as deps or provides, is a package name
+func checkPkgList(val any, name string, findings *[]Finding) {
+	valSlice, ok := mustBeArray(val, name, findings)
+	if !ok {
+		return
+	}
+
+	for i, pkg := range valSlice {
+		if !isPkgName(pkg) {
+			*findings = append(*findings, Finding{
+				ItemType: "element",
+				ItemName: name,
+				Index:    i,
+				Msg:      "The %s must be a package name, without spaces",
+				Rule:     RulePackageList,
+			})
+		}
+	}
+}
+
+// checkOptDeps checks that every element of opt_deps is a package
+// name, optionally followed by a colon and a description
+func checkOptDeps(val any, name string, findings *[]Finding) {
+	valSlice, ok := mustBeArray(val, name, findings)
+	if !ok {
+		return
+	}
+
+	for i, dep := range valSlice {
+		pkg, desc, hasDesc := strings.Cut(dep, ":")
+		if !isPkgName(pkg) {
+			*findings = append(*findings, Finding{
+				ItemType: "element",
+				ItemName: name,
+				Index:    i,
+				Msg:      "The %s must start with a package name, optionally followed by a colon and a description (e.g. 'foo: for bar support')",
+				Rule:     RuleOptDeps,
+			})
+		} else if hasDesc && strings.TrimSpace(desc) == "" {
+			*findings = append(*findings, Finding{
+				ItemType: "element",
+				ItemName: name,
+				Index:    i,
+				Msg:      "The %s has an empty description after the colon",
+				Rule:     RuleOptDeps,
+				Severity: SeverityWarning,
+			})
+		}
+	}
+}
+
+// checkBuildVars checks that every element of
+// build_vars is an assignment, like NAME=value
+func checkBuildVars(val any, name string, findings *[]Finding) {
+	valSlice, ok := mustBeArray(val, name, findings)
+	if !ok {
+		return
+	}
+
+	for i, v := range valSlice {
+		varName, _, ok := strings.Cut(v, "=")
+		if !ok || !syntax.ValidName(varName) {
+			*findings = append(*findings, Finding{
+				ItemType: "element",
+				ItemName: name,
+				Index:    i,
+				Msg:      "The %s must be an assignment, like NAME=value",
+				Rule:     RuleBuildVars,
+			})
+		}
+	}
+}
+
+// checkBool checks boolean variables like auto_req,
+// which can be strings or single-element arrays
+func checkBool(val any, name string, findings *[]Finding) {
+	var values []string
+	switch val := val.(type) {
+	case string:
+		values = []string{val}
+	case []string:
+		values = val
+	default:
+		*findings = append(*findings, Finding{
+			ItemType: "variable",
+			ItemName: name,
+			Msg:      "The %s must be a string",
+			Rule:     RuleType,
+		})
+		return
+	}
+
+	for _, v := range values {
+		if !slices.Contains(boolValues, strings.ToLower(v)) {
+			*findings = append(*findings, Finding{
+				ItemType: "variable",
+				ItemName: name,
+				Msg:      "The %s must be one of: " + strings.Join(boolValues, ", "),
+				Rule:     RuleBoolean,
+			})
+			return
+		}
+	}
+}
+
+func checkGroup(val any, name string, findings *[]Finding) {
+	valStr, ok := mustBeStr(val, name, findings)
+	if !ok {
+		return
+	}
+
+	if strings.Contains(valStr, "\n") {
+		*findings = append(*findings, Finding{
+			ItemType: "variable",
+			ItemName: name,
+			Msg:      "The %s must be a single line",
+			Rule:     RuleGroup,
+		})
+	}
+}
+
+func checkSection(val any, name string, findings *[]Finding) {
+	valStr, ok := mustBeStr(val, name, findings)
+	if !ok {
+		return
+	}
+
+	section := valStr
+	if area, s, ok := strings.Cut(valStr, "/"); ok && slices.Contains([]string{"main", "contrib", "non-free", "non-free-firmware"}, area) {
+		section = s
+	}
+
+	if !slices.Contains(debSections, section) {
+		f := Finding{
+			ItemType: "variable",
+			ItemName: name,
+			Msg:      "The %s contains an unknown section: '" + escapeMsg(valStr) + "'.",
+			Rule:     RuleSection,
+			Severity: SeverityWarning,
+		}
+		if similar := findSimilar(section, debSections); similar != "" {
+			f.Msg += " Did you mean '" + similar + "'?"
+		}
+		*findings = append(*findings, f)
+	}
+}
+
+func checkPriority(val any, name string, findings *[]Finding) {
+	valStr, ok := mustBeStr(val, name, findings)
+	if !ok {
+		return
+	}
+
+	if !slices.Contains(debPriorities, valStr) {
+		*findings = append(*findings, Finding{
+			ItemType: "variable",
+			ItemName: name,
+			Msg:      "The %s must be one of: " + strings.Join(debPriorities, ", "),
+			Rule:     RulePriority,
+		})
+	} else if valStr == "extra" {
+		*findings = append(*findings, Finding{
+			ItemType: "variable",
+			ItemName: name,
+			Msg:      "The %s is set to 'extra', which is deprecated. Use 'optional' instead.",
+			Rule:     RulePriority,
+			Severity: SeverityWarning,
+		})
+	}
+}
+
+// checkBackup checks that every file in backup is an absolute path
+func checkBackup(val any, name string, findings *[]Finding) {
+	valSlice, ok := mustBeArray(val, name, findings)
+	if !ok {
+		return
+	}
+
+	for i, path := range valSlice {
+		if !strings.HasPrefix(path, "/") {
+			*findings = append(*findings, Finding{
+				ItemType: "element",
+				ItemName: name,
+				Index:    i,
+				Msg:      "The %s must be an absolute path, like /etc/foo.conf",
+				Rule:     RuleBackup,
+			})
+		}
+	}
+}
+
+// isPkgName checks that s could be a package name
+func isPkgName(s string) bool {
+	return s != "" && strings.IndexFunc(s, unicode.IsSpace) == -1
+}
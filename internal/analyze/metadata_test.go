@@ -0,0 +1,155 @@
+package analyze
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.arsenm.dev/lure-repo-bot/internal/sandbox"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+func TestMetadataChecks(t *testing.T) {
+	tests := []struct {
+		name   string
+		check  func(val any, name string, findings *[]Finding)
+		val    any
+		wanted []string
+	}{
+		{"desc", checkDesc, "A tool", nil},
+		{"empty desc", checkDesc, nil, []string{RuleDesc}},
+		{"multiline desc", checkDesc, "A\ntool", []string{RuleDesc}},
+		{"long desc", checkDesc, strings.Repeat("a", 81), []string{RuleDesc}},
+		{"desc at limit", checkDesc, strings.Repeat("a", 80), nil},
+		{"array desc", checkDesc, []string{"a"}, []string{RuleType}},
+
+		{"package list", checkPkgList, []string{"foo", "libfoo-dev"}, nil},
+		{"package with space", checkPkgList, []string{"foo", "bar baz"}, []string{RulePackageList}},
+		{"empty package", checkPkgList, []string{""}, []string{RulePackageList}},
+		{"string package list", checkPkgList, "foo", []string{RuleType}},
+
+		{"opt deps", checkOptDeps, []string{"foo", "bar: for bar support"}, nil},
+		{"opt dep without name", checkOptDeps, []string{": for bar support"}, []string{RuleOptDeps}},
+		{"opt dep with space", checkOptDeps, []string{"foo bar"}, []string{RuleOptDeps}},
+		{"opt dep empty description", checkOptDeps, []string{"foo: "}, []string{RuleOptDeps}},
+
+		{"build vars", checkBuildVars, []string{"CGO_ENABLED=0", "GOFLAGS="}, nil},
+		{"build var without equals", checkBuildVars, []string{"CGO_ENABLED"}, []string{RuleBuildVars}},
+		{"build var invalid name", checkBuildVars, []string{"1X=1", "A-B=2"}, []string{RuleBuildVars, RuleBuildVars}},
+
+		{"bool", checkBool, "yes", nil},
+		{"bool uppercase", checkBool, "False", nil},
+		{"bool array", checkBool, []string{"1"}, nil},
+		{"invalid bool", checkBool, "maybe", []string{RuleBoolean}},
+		{"map bool", checkBool, map[string]string{"a": "b"}, []string{RuleType}},
+
+		{"group", checkGroup, "Applications/System", nil},
+		{"multiline group", checkGroup, "a\nb", []string{RuleGroup}},
+
+		{"section", checkSection, "utils", nil},
+		{"section with area", checkSection, "contrib/utils", nil},
+		{"section with firmware area", checkSection, "non-free-firmware/kernel", nil},
+		{"unknown section", checkSection, "utlis", []string{RuleSection}},
+		{"unknown area", checkSection, "foo/utils", []string{RuleSection}},
+
+		{"priority", checkPriority, "optional", nil},
+		{"extra priority", checkPriority, "extra", []string{RulePriority}},
+		{"invalid priority", checkPriority, "high", []string{RulePriority}},
+
+		{"backup", checkBackup, []string{"/etc/foo.conf"}, nil},
+		{"relative backup", checkBackup, []string{"/etc/foo.conf", "etc/bar.conf"}, []string{RuleBackup}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var findings []Finding
+			tt.check(tt.val, "var", &findings)
+
+			var got []string
+			for _, f := range findings {
+				got = append(got, f.Rule)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wanted, ",") {
+				t.Errorf("got %v, wanted %v", got, tt.wanted)
+			}
+		})
+	}
+}
+
+func TestMetadataMessages(t *testing.T) {
+	tests := []struct {
+		name     string
+		check    func(val any, name string, findings *[]Finding)
+		val      any
+		severity Severity
+		msg      string
+	}{
+		{"long desc", checkDesc, strings.Repeat("a", 81), SeverityWarning, "cut off"},
+		{"opt dep empty description", checkOptDeps, []string{"foo:"}, SeverityWarning, "empty description"},
+		{"extra priority", checkPriority, "extra", SeverityWarning, "Use 'optional' instead"},
+		{"invalid priority", checkPriority, "high", SeverityError, "required, important, standard, optional, extra"},
+		{"section suggestion", checkSection, "contrib/utlis", SeverityWarning, "Did you mean 'utils'?"},
+		{"invalid bool", checkBool, "maybe", SeverityError, "yes, no, true, false, 1, 0"},
+		{"section with percent", checkSection, "100%s", SeverityWarning, "unknown section: '100%s'."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var findings []Finding
+			tt.check(tt.val, "var", &findings)
+			if len(findings) != 1 {
+				t.Fatalf("got %d findings, wanted 1", len(findings))
+			}
+
+			f := findings[0]
+			if f.Severity != tt.severity {
+				t.Errorf("got severity %v, wanted %v", f.Severity, tt.severity)
+			}
+			if msg := f.Message(false); !strings.Contains(msg, tt.msg) {
+				t.Errorf("got message %q, wanted it to contain %q", msg, tt.msg)
+			}
+		})
+	}
+}
+
+func TestFirmwareVars(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		wanted []string
+	}{
+		{"firmware", "firmware=(foo.bin)", nil},
+		{"firmware field", "firmware_files=(foo.bin)", nil},
+		{"firmware field string", "firmware_files=foo.bin", []string{RuleType}},
+		{"other override", "deps_ubunut=(foo)", []string{RuleOverrides}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fl, err := syntax.NewParser().Parse(strings.NewReader(tt.src), "lure.sh")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := sandbox.Evaluate(context.Background(), fl, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			findings, err := AnalyzeScript(res, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, f := range findings {
+				if f.Rule == RuleType || f.Rule == RuleOverrides {
+					got = append(got, f.Rule)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wanted, ",") {
+				t.Errorf("got %v, wanted %v", got, tt.wanted)
+			}
+		})
+	}
+}
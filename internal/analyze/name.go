@@ -0,0 +1,73 @@
+package analyze
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// checkName checks the package name against the naming policy.
+// dir is the name of the directory containing the script, or
+// an empty string if it's unknown.
+func checkName(val any, name, dir string, vars map[string]expand.Variable, funcs map[string]*syntax.Stmt, findings *[]Finding) {
+	pkgName, ok := mustBeStr(val, name, findings)
+	if !ok {
+		return
+	}
+
+	add := func(severity Severity, msg string) {
+		*findings = append(*findings, Finding{
+			ItemType: "variable",
+			ItemName: name,
+			Msg:      msg,
+			Rule:     RuleName,
+			Severity: severity,
+		})
+	}
+
+	if lower := strings.ToLower(pkgName); lower != pkgName {
+		add(SeverityError, "The %s must be lowercase. Use '"+escapeMsg(lower)+"' instead.")
+	} else if i := strings.IndexFunc(pkgName, func(r rune) bool { return !isNameChar(r) }); i != -1 {
+		r, _ := utf8.DecodeRuneInString(pkgName[i:])
+		add(SeverityError, "The %s contains '"+escapeMsg(string(r))+"', but it can only contain lowercase letters, digits, and the characters '+', '-', and '.'")
+	} else if pkgName != "" && strings.ContainsRune("+-.", rune(pkgName[0])) {
+		add(SeverityError, "The %s must start with a letter")
+	} else if pkgName != "" && pkgName[0] >= '0' && pkgName[0] <= '9' {
+		add(SeverityWarning, "The %s starts with a digit, which some package managers don't allow")
+	}
+
+	if strings.HasSuffix(pkgName, "-git") && !hasGitSource(vars) {
+		add(SeverityError, "The %s ends with -git, but the package doesn't have any git sources.")
+	}
+
+	if _, ok := funcs["build"]; ok && strings.HasSuffix(pkgName, "-bin") {
+		add(SeverityWarning, "The %s ends with -bin, but the package has a build function. -bin packages should install prebuilt files.")
+	}
+
+	if dir != "" && dir != pkgName {
+		add(SeverityError, "The %s must be the same as the name of the package's directory, '"+escapeMsg(dir)+"'")
+	}
+}
+
+// hasGitSource checks whether any of the sources
+// arrays, including overrides, contain a git source
+func hasGitSource(vars map[string]expand.Variable) bool {
+	for name, v := range vars {
+		if base, _, _ := ParseVarName(name); base != "sources" {
+			continue
+		}
+
+		for _, src := range v.List {
+			if strings.HasPrefix(src, "git+") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isNameChar(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || strings.ContainsRune("+-.", r)
+}
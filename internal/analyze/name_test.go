@@ -0,0 +1,83 @@
+package analyze
+
+import (
+	"strings"
+	"testing"
+
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+func TestCheckName(t *testing.T) {
+	gitSources := map[string]expand.Variable{
+		"sources": {Kind: expand.Indexed, List: []string{"git+https://example.com/foo.git"}},
+	}
+	overrideGitSources := map[string]expand.Variable{
+		"sources":        {Kind: expand.Indexed, List: []string{"https://example.com/foo.tar.gz"}},
+		"sources_ubuntu": {Kind: expand.Indexed, List: []string{"git+https://example.com/foo.git"}},
+	}
+	tarSources := map[string]expand.Variable{
+		"sources": {Kind: expand.Indexed, List: []string{"https://example.com/foo.tar.gz"}},
+	}
+	buildFunc := map[string]*syntax.Stmt{"build": {}}
+
+	type result struct {
+		severity Severity
+		msg      string
+	}
+
+	tests := []struct {
+		name   string
+		val    any
+		dir    string
+		vars   map[string]expand.Variable
+		funcs  map[string]*syntax.Stmt
+		wanted []result
+	}{
+		{"valid", "foo", "foo", nil, nil, nil},
+		{"symbols", "foo+bar-1.0", "", nil, nil, nil},
+		{"unknown directory", "foo", "", nil, nil, nil},
+		{"uppercase", "Foo", "", nil, nil, []result{{SeverityError, "Use 'foo' instead."}}},
+		{"invalid character", "foo_bar", "", nil, nil, []result{{SeverityError, "contains '_'"}}},
+		{"space", "foo bar", "", nil, nil, []result{{SeverityError, "contains ' '"}}},
+		{"non-ascii", "foö", "", nil, nil, []result{{SeverityError, "contains 'ö'"}}},
+		{"percent", "foo%d", "", nil, nil, []result{{SeverityError, "contains '%'"}}},
+		{"uppercase with percent", "Foo%d", "", nil, nil, []result{{SeverityError, "Use 'foo%d' instead."}}},
+		{"directory with percent", "foo", "foo%d", nil, nil, []result{{SeverityError, "directory, 'foo%d'"}}},
+		{"starts with symbol", "-foo", "", nil, nil, []result{{SeverityError, "must start with a letter"}}},
+		{"starts with digit", "0ad", "", nil, nil, []result{{SeverityWarning, "starts with a digit"}}},
+		{"directory", "foo", "bar", nil, nil, []result{{SeverityError, "directory, 'bar'"}}},
+		{"git", "foo-git", "", gitSources, nil, nil},
+		{"git override", "foo-git", "", overrideGitSources, nil, nil},
+		{"git without git sources", "foo-git", "", tarSources, nil, []result{{SeverityError, "doesn't have any git sources"}}},
+		{"bin", "foo-bin", "", tarSources, nil, nil},
+		{"bin with build", "foo-bin", "", tarSources, buildFunc, []result{{SeverityWarning, "has a build function"}}},
+		{"build", "foo", "", tarSources, buildFunc, nil},
+		{"several", "Foo-git", "foo", nil, nil, []result{
+			{SeverityError, "must be lowercase"},
+			{SeverityError, "doesn't have any git sources"},
+			{SeverityError, "directory, 'foo'"},
+		}},
+		{"array", []string{"foo"}, "", nil, nil, []result{{SeverityError, "must be a string"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var findings []Finding
+			checkName(tt.val, "name", tt.dir, tt.vars, tt.funcs, &findings)
+
+			if len(findings) != len(tt.wanted) {
+				t.Fatalf("got %d findings, wanted %d", len(findings), len(tt.wanted))
+			}
+
+			for i, f := range findings {
+				if f.Severity != tt.wanted[i].severity {
+					t.Errorf("got severity %v, wanted %v", f.Severity, tt.wanted[i].severity)
+				}
+				if msg := f.Message(false); !strings.Contains(msg, tt.wanted[i].msg) {
+					t.Errorf("got message %q, wanted it to contain %q", msg, tt.wanted[i].msg)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package analyze
+
+import (
+	"strings"
+
+	"github.com/adrg/strutil/metrics"
+	"golang.org/x/exp/slices"
+)
+
+// Architectures contains the architecture names that LURE
+// uses for $ARCH and for architecture override suffixes
+var Architectures = []string{
+	"all",
+	"amd64",
+	"386",
+	"arm64",
+	"arm7",
+	"arm6",
+	"arm5",
+	"riscv64",
+	"loong64",
+	"mips64",
+	"mips64le",
+	"mips",
+	"mipsle",
+	"ppc64",
+	"ppc64le",
+	"s390x",
+}
+
+// archAliases maps common names for architectures,
+// such as the ones used by uname and other package
+// managers, to the names LURE uses
+var archAliases = map[string]string{
+	"x86_64":      "amd64",
+	"x64":         "amd64",
+	"i386":        "386",
+	"i486":        "386",
+	"i586":        "386",
+	"i686":        "386",
+	"x86":         "386",
+	"aarch64":     "arm64",
+	"armv8":       "arm64",
+	"armv7":       "arm7",
+	"armv7l":      "arm7",
+	"armv7h":      "arm7",
+	"armhf":       "arm7",
+	"armv6":       "arm6",
+	"armv6l":      "arm6",
+	"armv6h":      "arm6",
+	"armel":       "arm5",
+	"armv5":       "arm5",
+	"armv5tel":    "arm5",
+	"riscv":       "riscv64",
+	"loongarch64": "loong64",
+	"mips64el":    "mips64le",
+	"mipsel":      "mipsle",
+	"ppc64el":     "ppc64le",
+	"powerpc64":   "ppc64",
+	"powerpc64le": "ppc64le",
+}
+
+// checkArchitectures checks that every element of
+// the architectures variable is a known architecture
+func checkArchitectures(archs []string, name string, findings *[]Finding) {
+	for i, arch := range archs {
+		if slices.Contains(Architectures, arch) {
+			if arch == "all" && len(archs) > 1 {
+				*findings = append(*findings, Finding{
+					ItemType: "element",
+					ItemName: name,
+					Index:    i,
+					Msg:      "The %s is 'all', so the other architectures are redundant",
+					Rule:     RuleArchitectures,
+					Severity: SeverityWarning,
+				})
+			}
+			continue
+		}
+
+		f := Finding{
+			ItemType: "element",
+			ItemName: name,
+			Index:    i,
+			Msg:      "The %s contains an unknown architecture: '" + escapeMsg(arch) + "'.",
+			ExtraMsg: "Valid architectures are: " + strings.Join(Architectures, ", "),
+			Rule:     RuleArchitectures,
+		}
+
+		if canonical, ok := archAliases[strings.ToLower(arch)]; ok {
+			f.Msg += " LURE calls it '" + canonical + "'."
+		} else if similar := findSimilar(arch, Architectures); similar != "" {
+			f.Msg += " Did you mean '" + similar + "'?"
+		}
+
+		*findings = append(*findings, f)
+	}
+}
+
+// Distros contains the IDs of common distributions, as found
+// in the ID and ID_LIKE fields of /etc/os-release. They can
+// be used as override suffixes. IDs containing '-', like
+// opensuse-leap, aren't included, since they can't be part of
+// a variable name.
+var Distros = []string{
+	"almalinux",
+	"alpine",
+	"amzn",
+	"arch",
+	"artix",
+	"centos",
+	"debian",
+	"deepin",
+	"elementary",
+	"endeavouros",
+	"fedora",
+	"garuda",
+	"gentoo",
+	"kali",
+	"linuxmint",
+	"mageia",
+	"manjaro",
+	"neon",
+	"nixos",
+	"ol",
+	"opensuse",
+	"pop",
+	"raspbian",
+	"rhel",
+	"rocky",
+	"slackware",
+	"sles",
+	"solus",
+	"suse",
+	"ubuntu",
+	"void",
+	"zorin",
+}
+
+// overridableVars are the LURE variables whose override
+// suffixes are checked. name and version aren't included,
+// since helper variables like version_major are common,
+// and neither is firmware, since the firmware_* variables
+// are separate fields rather than overrides.
+var overridableVars = []string{
+	"release", "epoch", "desc", "homepage", "maintainer",
+	"architectures", "license", "provides", "conflicts",
+	"deps", "build_deps", "build_vars", "opt_deps", "replaces",
+	"sources", "checksums", "backup", "scripts",
+	"auto_req", "auto_prov",
+	"group", "section", "priority",
+}
+
+// overrideFindings checks that the override suffix of
+// a variable is a distro, an architecture, or a distro
+// followed by an architecture. Architecture overrides
+// must be for one of the package's architectures.
+func overrideFindings(name, suffix string, archs []string) []Finding {
+	if slices.Contains(Distros, suffix) {
+		return nil
+	} else if isArch(suffix) {
+		return unusedArchFindings(name, suffix, archs)
+	}
+
+	// Other names for architectures, like x86_64, are checked
+	// before cutting the suffix, since they can contain '_'
+	var canonical, similar string
+	distro, arch, ok := strings.Cut(suffix, "_")
+	if alias, isAlias := archAliases[strings.ToLower(suffix)]; isAlias {
+		canonical = alias
+	} else if ok && slices.Contains(Distros, distro) {
+		if isArch(arch) {
+			return unusedArchFindings(name, arch, archs)
+		}
+		if alias, isAlias := archAliases[strings.ToLower(arch)]; isAlias {
+			similar = distro + "_" + alias
+		} else if sim := findSimilar(arch, overrideArchs()); sim != "" {
+			similar = distro + "_" + sim
+		}
+	} else {
+		similar = findSimilar(suffix, append(slices.Clone(Distros), overrideArchs()...))
+	}
+
+	f := Finding{
+		ItemType: "variable",
+		ItemName: name,
+		Msg:      "The %s has an unknown override suffix: '" + escapeMsg(suffix) + "'.",
+		ExtraMsg: "Overrides must end with a distro ID, an architecture, or a distro ID followed by an architecture.",
+		Rule:     RuleOverrides,
+		Severity: SeverityWarning,
+	}
+	if canonical != "" {
+		f.Msg += " LURE calls it '" + canonical + "'."
+	} else if similar != "" {
+		f.Msg += " Did you mean '" + similar + "'?"
+	}
+	return []Finding{f}
+}
+
+// unusedArchFindings checks that arch is one of the architectures
+// in archs, since overrides for other architectures are never used
+func unusedArchFindings(name, arch string, archs []string) []Finding {
+	if len(archs) == 0 || slices.Contains(archs, "all") || slices.Contains(archs, arch) {
+		return nil
+	}
+
+	return []Finding{{
+		ItemType: "variable",
+		ItemName: name,
+		Msg:      "The %s is an override for '" + escapeMsg(arch) + "', which isn't in the architectures variable, so it will never be used",
+		Rule:     RuleArchitectures,
+		Severity: SeverityWarning,
+	}}
+}
+
+func isArch(s string) bool {
+	return s != "all" && slices.Contains(Architectures, s)
+}
+
+// overrideArchs returns the architectures that
+// can be used as override suffixes
+func overrideArchs() []string {
+	var out []string
+	for _, arch := range Architectures {
+		if isArch(arch) {
+			out = append(out, arch)
+		}
+	}
+	return out
+}
+
+// minSimilarity is the minimum Jaro-Winkler similarity
+// for findSimilar to suggest a replacement
+const minSimilarity = 0.8
+
+// findSimilar returns the option most similar to s,
+// or an empty string if none are similar enough
+func findSimilar(s string, options []string) string {
+	jw := metrics.NewJaroWinkler()
+	jw.CaseSensitive = false
+
+	best, bestSim := "", 0.0
+	for _, option := range options {
+		sim := jw.Compare(s, option)
+		if sim > bestSim {
+			best, bestSim = option, sim
+		}
+	}
+
+	if bestSim < minSimilarity {
+		return ""
+	}
+	return best
+}
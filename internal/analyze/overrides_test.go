@@ -0,0 +1,162 @@
+package analyze
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindSimilar(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		options []string
+		wanted  string
+	}{
+		{"typo", "ubunut", Distros, "ubuntu"},
+		{"prefix", "deb", Distros, "debian"},
+		{"case", "UBUNTU", Distros, "ubuntu"},
+		{"best", "arm", []string{"arm64", "arm7"}, "arm7"},
+		{"above threshold", "abc", []string{"abd"}, "abd"},
+		{"just below threshold", "abcde", []string{"bc"}, ""},
+		{"below threshold", "abcdef", []string{"abcxyz"}, ""},
+		{"unrelated", "mint", Distros, ""},
+		{"no options", "ubuntu", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findSimilar(tt.s, tt.options); got != tt.wanted {
+				t.Errorf("got %q, wanted %q", got, tt.wanted)
+			}
+		})
+	}
+}
+
+func TestOverrideFindings(t *testing.T) {
+	tests := []struct {
+		name    string
+		suffix  string
+		archs   []string
+		rule    string
+		similar string
+	}{
+		{"distro", "ubuntu", nil, "", ""},
+		{"arch", "arm64", []string{"amd64", "arm64"}, "", ""},
+		{"arch without architectures", "arm64", nil, "", ""},
+		{"arch with all", "arm64", []string{"all"}, "", ""},
+		{"unused arch", "arm64", []string{"amd64"}, RuleArchitectures, ""},
+		{"distro and arch", "debian_arm64", []string{"arm64"}, "", ""},
+		{"distro and unused arch", "debian_arm64", []string{"amd64"}, RuleArchitectures, ""},
+		{"misspelled distro", "ubunut", nil, RuleOverrides, "ubuntu"},
+		{"misspelled arch", "amd46", nil, RuleOverrides, "amd64"},
+		{"misspelled arch after distro", "debian_amd46", nil, RuleOverrides, "debian_amd64"},
+		{"all", "all", nil, RuleOverrides, "almalinux"},
+		{"alias", "x86_64", nil, RuleOverrides, ""},
+		{"distro and alias", "ubuntu_x86_64", nil, RuleOverrides, "ubuntu_amd64"},
+		{"distro and uppercase alias", "debian_AARCH64", nil, RuleOverrides, "debian_arm64"},
+		{"unknown distro", "foo", nil, RuleOverrides, ""},
+		{"unknown distro and arch", "foo_amd64", nil, RuleOverrides, "amd64"},
+		{"uppercase distro", "Ubuntu", nil, RuleOverrides, "ubuntu"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := overrideFindings("deps_"+tt.suffix, tt.suffix, tt.archs)
+			if tt.rule == "" {
+				if len(findings) != 0 {
+					t.Fatalf("got %d findings, wanted none", len(findings))
+				}
+				return
+			}
+
+			if len(findings) != 1 {
+				t.Fatalf("got %d findings, wanted 1", len(findings))
+			}
+
+			f := findings[0]
+			if f.Rule != tt.rule {
+				t.Errorf("got rule %q, wanted %q", f.Rule, tt.rule)
+			}
+			if f.ItemName != "deps_"+tt.suffix {
+				t.Errorf("got item %q, wanted %q", f.ItemName, "deps_"+tt.suffix)
+			}
+
+			msg := f.Message(false)
+			hasSuggestion := strings.Contains(msg, "Did you mean")
+			if tt.similar == "" && hasSuggestion {
+				t.Errorf("got message %q, wanted no suggestion", msg)
+			} else if tt.similar != "" && !strings.Contains(msg, "Did you mean '"+tt.similar+"'?") {
+				t.Errorf("got message %q, wanted it to suggest %q", msg, tt.similar)
+			}
+		})
+	}
+}
+
+func TestCheckArchitectures(t *testing.T) {
+	tests := []struct {
+		name   string
+		archs  []string
+		wanted []int
+		msg    string
+	}{
+		{"valid", []string{"amd64", "arm64", "386"}, nil, ""},
+		{"all", []string{"all"}, nil, ""},
+		{"all with others", []string{"amd64", "all"}, []int{1}, "redundant"},
+		{"alias", []string{"amd64", "x86_64"}, []int{1}, "LURE calls it 'amd64'"},
+		{"uppercase alias", []string{"AARCH64"}, []int{0}, "LURE calls it 'arm64'"},
+		{"armhf", []string{"armhf"}, []int{0}, "LURE calls it 'arm7'"},
+		{"typo", []string{"amd46"}, []int{0}, "Did you mean 'amd64'?"},
+		{"noarch", []string{"noarch"}, []int{0}, "unknown architecture: 'noarch'"},
+		{"percent", []string{"amd%d"}, []int{0}, "unknown architecture: 'amd%d'."},
+		{"several", []string{"x86_64", "arm64", "i686"}, []int{0, 2}, "LURE calls it"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var findings []Finding
+			checkArchitectures(tt.archs, "architectures", &findings)
+
+			if len(findings) != len(tt.wanted) {
+				t.Fatalf("got %d findings, wanted %d", len(findings), len(tt.wanted))
+			}
+
+			for i, f := range findings {
+				if f.Index != tt.wanted[i] {
+					t.Errorf("got index %v, wanted %d", f.Index, tt.wanted[i])
+				}
+				if f.Rule != RuleArchitectures {
+					t.Errorf("got rule %q, wanted %q", f.Rule, RuleArchitectures)
+				}
+				if msg := f.Message(false); !strings.Contains(msg, tt.msg) {
+					t.Errorf("got message %q, wanted it to contain %q", msg, tt.msg)
+				}
+			}
+		})
+	}
+}
+
+func TestOverrideFindingsAlias(t *testing.T) {
+	tests := []struct {
+		name      string
+		suffix    string
+		canonical string
+	}{
+		{"x86_64", "x86_64", "amd64"},
+		{"uppercase", "AARCH64", "arm64"},
+		{"armhf", "armhf", "arm7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := overrideFindings("sources_"+tt.suffix, tt.suffix, nil)
+			if len(findings) != 1 {
+				t.Fatalf("got %d findings, wanted 1", len(findings))
+			}
+
+			msg := findings[0].Message(false)
+			if !strings.Contains(msg, "LURE calls it '"+tt.canonical+"'") {
+				t.Errorf("got message %q, wanted it to suggest %q", msg, tt.canonical)
+			}
+		})
+	}
+}
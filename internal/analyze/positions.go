@@ -0,0 +1,228 @@
+package analyze
+
+import (
+	"strconv"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Range is a span of text in a script. End is
+// the position right after the end of the span.
+type Range struct {
+	Start syntax.Pos
+	End   syntax.Pos
+}
+
+// NodeRange returns the range covered by node
+func NodeRange(node syntax.Node) Range {
+	return Range{node.Pos(), node.End()}
+}
+
+// Positions is an index of where variables, array
+// elements, and functions are defined in a script
+type Positions struct {
+	// Vars contains the positions of every top-level
+	// assignment to each variable, in order
+	Vars map[string][]Range
+	// Elems contains the positions of the elements of each array
+	// or map, by key. For indexed arrays, the key is the index.
+	Elems map[string]map[string]Range
+	// Funcs contains the positions of function declarations,
+	// up to the end of the function's name
+	Funcs map[string]Range
+}
+
+// Var returns the position of the last top-level
+// assignment to a variable, since that's the one
+// that determines its value
+func (p Positions) Var(name string) (Range, bool) {
+	positions := p.Vars[name]
+	if len(positions) == 0 {
+		return Range{}, false
+	}
+	return positions[len(positions)-1], true
+}
+
+// Elem returns the position of an element of an array or map
+func (p Positions) Elem(name string, key any) (Range, bool) {
+	pos, ok := p.Elems[name][toKey(key)]
+	return pos, ok
+}
+
+// FindPositions builds a position index for fl. Assignments
+// inside function bodies aren't included, since they don't
+// affect the script's top-level variables.
+func FindPositions(fl *syntax.File) Positions {
+	out := Positions{
+		Vars:  map[string][]Range{},
+		Elems: map[string]map[string]Range{},
+		Funcs: map[string]Range{},
+	}
+
+	indexes := indexTracker{}
+
+	syntax.Walk(fl, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.FuncDecl:
+			out.Funcs[node.Name.Value] = Range{node.Pos(), node.Name.End()}
+			return false
+		case *syntax.Assign:
+			if node.Name == nil {
+				return true
+			}
+			name := node.Name.Value
+			out.Vars[name] = append(out.Vars[name], NodeRange(node))
+
+			// Assigning an array literal removes the old elements
+			if out.Elems[name] == nil || (!node.Append && node.Array != nil) {
+				out.Elems[name] = map[string]Range{}
+			}
+
+			for _, elem := range indexes.elems(node) {
+				out.Elems[name][elem.key] = NodeRange(elem.value)
+			}
+		}
+		return true
+	})
+
+	return out
+}
+
+// indexTracker tracks the index after the highest index
+// of each indexed array, where appended elements start
+type indexTracker map[string]int
+
+// arrayElem is an element assigned to an array or map
+type arrayElem struct {
+	key   string
+	value *syntax.Word
+}
+
+// elems returns the elements assigned by node, with the same
+// indexes bash would give them, and updates the array's end.
+// Assigning a string to an array only replaces its first
+// element. Elements whose key isn't a literal are skipped.
+func (t indexTracker) elems(node *syntax.Assign) []arrayElem {
+	name := node.Name.Value
+
+	switch {
+	case node.Naked:
+		// Declarations without a value, like declare -a arr
+		return nil
+	case node.Index != nil:
+		// Single element assignment, like sources[3]=...
+		key, ok := indexKey(node.Index)
+		if !ok || node.Value == nil {
+			return nil
+		}
+		t.extend(name, key)
+		return []arrayElem{{key, node.Value}}
+	case node.Array == nil:
+		if node.Append || node.Value == nil {
+			return nil
+		}
+		t.extend(name, "0")
+		return []arrayElem{{"0", node.Value}}
+	}
+
+	if !node.Append {
+		t[name] = 0
+	}
+
+	// Inside an array literal, elements without an index
+	// come after the previous element, not the highest one
+	next := t[name]
+
+	var out []arrayElem
+	for _, elem := range node.Array.Elems {
+		key := strconv.Itoa(next)
+		if elem.Index != nil {
+			var ok bool
+			key, ok = indexKey(elem.Index)
+			if !ok {
+				continue
+			}
+		}
+
+		if i, err := strconv.Atoi(key); err == nil {
+			next = i + 1
+		}
+		t.extend(name, key)
+
+		if elem.Value != nil {
+			out = append(out, arrayElem{key, elem.Value})
+		}
+	}
+	return out
+}
+
+// extend moves the end of the array past key, if it's an index
+func (t indexTracker) extend(name, key string) {
+	if i, err := strconv.Atoi(key); err == nil && i+1 > t[name] {
+		t[name] = i + 1
+	}
+}
+
+// setPositions sets the ranges of the findings that don't
+// have one yet, based on the items they're about
+func setPositions(findings []Finding, fl *syntax.File) {
+	positions := FindPositions(fl)
+	for i, finding := range findings {
+		if finding.StartLine != 0 {
+			continue
+		}
+
+		var (
+			rng Range
+			ok  bool
+		)
+		if finding.ItemType == "function" {
+			rng, ok = positions.Funcs[finding.ItemName]
+		} else if finding.Index != nil {
+			rng, ok = positions.Elem(finding.ItemName, finding.Index)
+			if !ok {
+				rng, ok = positions.Var(finding.ItemName)
+			}
+		} else {
+			rng, ok = positions.Var(finding.ItemName)
+		}
+
+		if ok {
+			findings[i].SetRange(rng)
+		}
+	}
+}
+
+// indexKey returns the literal key of an array index
+// expression, such as 3 in arr[3] or foo in map[foo]
+func indexKey(expr syntax.ArithmExpr) (string, bool) {
+	w, ok := expr.(*syntax.Word)
+	if !ok {
+		return "", false
+	}
+
+	if lit := w.Lit(); lit != "" {
+		return lit, true
+	}
+
+	// Map keys are often quoted, like map["foo"]
+	switch part := firstPart(w).(type) {
+	case *syntax.Lit:
+		return part.Value, len(w.Parts) == 1
+	case *syntax.SglQuoted:
+		return part.Value, len(w.Parts) == 1
+	}
+
+	return "", false
+}
+
+func toKey(key any) string {
+	switch key := key.(type) {
+	case string:
+		return key
+	case int:
+		return strconv.Itoa(key)
+	default:
+		return ""
+	}
+}
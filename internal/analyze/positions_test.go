@@ -0,0 +1,156 @@
+package analyze
+
+import (
+	"strings"
+	"testing"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// rangeText returns the text of src covered by r
+func rangeText(src string, r Range) string {
+	return src[r.Start.Offset():r.End.Offset()]
+}
+
+func parsePositions(t *testing.T, src string) Positions {
+	t.Helper()
+	fl, err := syntax.NewParser().Parse(strings.NewReader(src), "lure.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return FindPositions(fl)
+}
+
+func TestFindPositionsVars(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		item   string
+		wanted []string
+	}{
+		{"simple", "a=1", "a", []string{"a=1"}},
+		{"same line first", "a=1 b=2", "a", []string{"a=1"}},
+		{"same line second", "a=1 b=2", "b", []string{"b=2"}},
+		{"reassigned", "a=1\na=2", "a", []string{"a=1", "a=2"}},
+		{"declare", "declare -a a=(x y)", "a", []string{"a=(x y)"}},
+		{"append", "a=(x)\na+=(y)", "a", []string{"a=(x)", "a+=(y)"}},
+		{"index", "sources[3]=x", "sources", []string{"sources[3]=x"}},
+		{"if", "if true; then\n\ta=1\nelse\n\ta=2\nfi", "a", []string{"a=1", "a=2"}},
+		{"function", "f() { a=1; }", "a", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			positions := parsePositions(t, tt.src)
+
+			var got []string
+			for _, r := range positions.Vars[tt.item] {
+				got = append(got, rangeText(tt.src, r))
+			}
+			if strings.Join(got, "|") != strings.Join(tt.wanted, "|") {
+				t.Errorf("got %q, wanted %q", got, tt.wanted)
+			}
+
+			r, ok := positions.Var(tt.item)
+			if ok != (len(tt.wanted) > 0) {
+				t.Fatalf("got ok %v, wanted %v", ok, len(tt.wanted) > 0)
+			}
+			if ok && rangeText(tt.src, r) != tt.wanted[len(tt.wanted)-1] {
+				t.Errorf("got last assignment %q, wanted %q", rangeText(tt.src, r), tt.wanted[len(tt.wanted)-1])
+			}
+		})
+	}
+}
+
+func TestFindPositionsElems(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		item   string
+		wanted map[string]string
+	}{
+		{"array", "a=(x y z)", "a", map[string]string{"0": "x", "1": "y", "2": "z"}},
+		{"multiline", "a=(\n\tx\n\ty\n)", "a", map[string]string{"0": "x", "1": "y"}},
+		{"declare", "declare -a a=(x y)", "a", map[string]string{"0": "x", "1": "y"}},
+		{"append", "a=(x)\na+=(y z)", "a", map[string]string{"0": "x", "1": "y", "2": "z"}},
+		{"reassigned", "a=(x y)\na=(z)", "a", map[string]string{"0": "z"}},
+		{"index", "sources[3]=x", "sources", map[string]string{"3": "x"}},
+		{"index after array", "sources=(x y)\nsources[1]=z", "sources", map[string]string{"0": "x", "1": "z"}},
+		{"explicit indices", "a=([2]=x y)", "a", map[string]string{"2": "x", "3": "y"}},
+		{"map", `declare -A m=([foo]=x ["bar"]=y ['baz']=z)`, "m", map[string]string{"foo": "x", "bar": "y", "baz": "z"}},
+		{"if", "if true; then\n\ta=(x)\nfi", "a", map[string]string{"0": "x"}},
+		{"dynamic index", "a[$i]=x", "a", map[string]string{}},
+		{"append after index", "sources[5]=a\nsources+=(b c)", "sources", map[string]string{"5": "a", "6": "b", "7": "c"}},
+		{"append after lower index", "a=(x y z)\na[0]=w\na+=(v)", "a", map[string]string{"0": "w", "1": "y", "2": "z", "3": "v"}},
+		{"append after string", "a=x\na+=(y)", "a", map[string]string{"0": "x", "1": "y"}},
+		{"append after declare", "a=(x)\ndeclare -a a\na+=(y)", "a", map[string]string{"0": "x", "1": "y"}},
+		{"reassigned after index", "a[5]=x\na=(y)\na+=(z)", "a", map[string]string{"0": "y", "1": "z"}},
+		{"append after explicit indices", "a=([5]=x [2]=y)\na+=(z)", "a", map[string]string{"5": "x", "2": "y", "6": "z"}},
+		{"explicit index in append", "a=(p q r)\na+=([1]=x y)", "a", map[string]string{"0": "p", "1": "x", "2": "y"}},
+		{"string replaces first element", "a=(x y)\na=w", "a", map[string]string{"0": "w", "1": "y"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			positions := parsePositions(t, tt.src)
+
+			got := map[string]string{}
+			for key, r := range positions.Elems[tt.item] {
+				got[key] = rangeText(tt.src, r)
+			}
+
+			if len(got) != len(tt.wanted) {
+				t.Fatalf("got %v, wanted %v", got, tt.wanted)
+			}
+			for key, text := range tt.wanted {
+				if got[key] != text {
+					t.Errorf("got %q for key %q, wanted %q", got[key], key, text)
+				}
+			}
+		})
+	}
+}
+
+func TestSetPositions(t *testing.T) {
+	const src = "name=foo version=1\n" +
+		"sources=(\n\ta\n\tb\n)\n" +
+		"if true; then\n\tdeps=(x)\nfi\n" +
+		"package() {\n\tname=bar\n}\n"
+
+	tests := []struct {
+		name    string
+		finding Finding
+		line    uint
+		col     uint
+		endCol  uint
+	}{
+		{"first on line", Finding{ItemType: "variable", ItemName: "name"}, 1, 1, 9},
+		{"second on line", Finding{ItemType: "variable", ItemName: "version"}, 1, 10, 19},
+		{"element", Finding{ItemType: "variable", ItemName: "sources", Index: 1}, 4, 2, 3},
+		{"missing element", Finding{ItemType: "variable", ItemName: "sources", Index: 5}, 2, 1, 0},
+		{"inside if", Finding{ItemType: "variable", ItemName: "deps"}, 7, 2, 10},
+		{"function", Finding{ItemType: "function", ItemName: "package"}, 9, 1, 8},
+		{"unknown", Finding{ItemType: "variable", ItemName: "release"}, 0, 0, 0},
+		{"already set", Finding{ItemType: "variable", ItemName: "name", StartLine: 20, StartCol: 3}, 20, 3, 0},
+	}
+
+	fl, err := syntax.NewParser().Parse(strings.NewReader(src), "lure.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := []Finding{tt.finding}
+			setPositions(findings, fl)
+
+			f := findings[0]
+			if f.StartLine != tt.line || f.StartCol != tt.col {
+				t.Errorf("got %d:%d, wanted %d:%d", f.StartLine, f.StartCol, tt.line, tt.col)
+			}
+			if tt.endCol != 0 && f.EndCol != tt.endCol {
+				t.Errorf("got end column %d, wanted %d", f.EndCol, tt.endCol)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package analyze
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.arsenm.dev/lure-repo-bot/internal/sandbox"
+	"golang.org/x/exp/slices"
+)
+
+// Reachability checks that a package's homepage and sources
+// can be downloaded. It's separate from AnalyzeScript, since
+// it makes network requests.
+type Reachability struct {
+	// Transport is used to make the requests. If it's nil, a
+	// transport that only connects to public addresses is used,
+	// so that scripts can't make the bot request internal services.
+	Transport http.RoundTripper
+	// Timeout is the timeout for each request. If it's
+	// zero, DefaultReachTimeout is used.
+	Timeout time.Duration
+}
+
+// DefaultReachTimeout is the default timeout for each request
+const DefaultReachTimeout = 15 * time.Second
+
+var errNotPublic = errors.New("address isn't public")
+
+// publicTransport is the default transport for Reachability.
+// The address is checked when each connection is made,
+// so it applies to redirects and can't be bypassed with
+// DNS records that point to a private address.
+var publicTransport = newPublicTransport()
+
+func newPublicTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   checkAddr,
+	}
+
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.DialContext = dialer.DialContext
+	// Connections to a proxy would be checked instead
+	// of the requested addresses, so proxies aren't used
+	tr.Proxy = nil
+	return tr
+}
+
+// checkAddr is a net.Dialer Control function that
+// refuses to connect to addresses that aren't public,
+// like loopback, private, and link-local addresses
+func checkAddr(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return errNotPublic
+	}
+
+	return nil
+}
+
+// Check checks the URLs in the script evaluated in res
+func (rc Reachability) Check(ctx context.Context, res *sandbox.Result) []Finding {
+	var findings []Finding
+	vars := res.Runner.Vars
+
+	if homepage := vars["homepage"].String(); homepage != "" {
+		if f, ok := rc.checkURL(ctx, homepage); ok {
+			f.ItemType = "variable"
+			f.ItemName = "homepage"
+			findings = append(findings, f)
+		}
+	}
+
+	for name, v := range vars {
+		if base, _, _ := ParseVarName(name); base != "sources" {
+			continue
+		}
+
+		for i, src := range v.List {
+			if f, ok := rc.checkURL(ctx, sourceURL(src)); ok {
+				f.ItemType = "element"
+				f.ItemName = name
+				f.Index = i
+				findings = append(findings, f)
+			}
+		}
+	}
+
+	setPositions(findings, res.File)
+	return findings
+}
+
+// checkURL requests rawURL and returns a finding if there's a problem.
+// The finding's item fields must be filled in by the caller.
+func (rc Reachability) checkURL(ctx context.Context, rawURL string) (Finding, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		// Invalid URLs are reported by AnalyzeScript
+		return Finding{}, false
+	}
+
+	f := Finding{Rule: RuleReachable}
+
+	resp, err := rc.request(ctx, http.MethodHead, u)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		// Some servers don't support HEAD requests
+		resp, err = rc.request(ctx, http.MethodGet, u)
+	}
+
+	var (
+		unknownErr x509.UnknownAuthorityError
+		hostErr    x509.HostnameError
+		invalidErr x509.CertificateInvalidError
+		recordErr  tls.RecordHeaderError
+	)
+	switch {
+	case err != nil && (errors.As(err, &unknownErr) || errors.As(err, &hostErr) ||
+		errors.As(err, &invalidErr) || errors.As(err, &recordErr)):
+		f.Msg = "The %s has a TLS error, so it can't be downloaded securely"
+	case err != nil:
+		// The error isn't included, since it could contain
+		// details about the network the bot is running on
+		f.Msg = "The %s couldn't be reached"
+		f.Severity = SeverityWarning
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		f.Msg = "The %s doesn't exist (HTTP " + strconv.Itoa(resp.StatusCode) + ")"
+	case resp.StatusCode >= 400:
+		f.Msg = "The %s returned an error (HTTP " + strconv.Itoa(resp.StatusCode) + ")"
+		f.Severity = SeverityWarning
+	case !sameHost(u, resp.Request.URL) && !slices.Contains(httpsHosts, strings.TrimPrefix(u.Hostname(), "www.")):
+		// Known hosts like github.com redirect downloads
+		// to their CDNs, so only other hosts are checked
+		f.Msg = "The %s redirects to a different host"
+		f.ExtraMsg = "This usually means that the project has moved. Use the URL it redirects to, so that the file doesn't depend on the redirect."
+		f.Severity = SeverityWarning
+	default:
+		return Finding{}, false
+	}
+
+	return f, true
+}
+
+// request makes a request and closes the response body,
+// since only the status and final URL are needed
+func (rc Reachability) request(ctx context.Context, method string, u *url.URL) (*http.Response, error) {
+	timeout := rc.Timeout
+	if timeout == 0 {
+		timeout = DefaultReachTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "lure-repo-bot")
+
+	transport := rc.Transport
+	if transport == nil {
+		transport = publicTransport
+	}
+
+	client := &http.Client{Transport: transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// sourceURL converts a LURE source to a URL that can be
+// requested, by removing the git+ prefix and LURE's parameters
+func sourceURL(src string) string {
+	u, err := url.Parse(strings.TrimPrefix(src, "git+"))
+	if err != nil {
+		return src
+	}
+
+	query := u.Query()
+	for name := range query {
+		if strings.HasPrefix(name, "~") {
+			query.Del(name)
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// sameHost checks whether a and b have the same host,
+// ignoring a www. prefix
+func sameHost(a, b *url.URL) bool {
+	return strings.TrimPrefix(a.Hostname(), "www.") == strings.TrimPrefix(b.Hostname(), "www.")
+}
@@ -0,0 +1,143 @@
+package analyze
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckURL(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/no-head", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/same-host", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusFound)
+	})
+	mux.HandleFunc("/other-host", func(w http.ResponseWriter, r *http.Request) {
+		// The server listens on 127.0.0.1, so
+		// localhost is a different host
+		u := "http://" + strings.Replace(r.Host, "127.0.0.1", "localhost", 1) + "/ok"
+		http.Redirect(w, r, u, http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tests := []struct {
+		path     string
+		msg      string
+		severity Severity
+	}{
+		{"/ok", "", SeverityError},
+		{"/missing", "The %s doesn't exist (HTTP 404)", SeverityError},
+		{"/error", "The %s returned an error (HTTP 500)", SeverityWarning},
+		{"/no-head", "", SeverityError},
+		{"/same-host", "", SeverityError},
+		{"/other-host", "The %s redirects to a different host", SeverityWarning},
+	}
+
+	// The test server is on a loopback address,
+	// so it has to be allowed explicitly
+	rc := Reachability{Transport: http.DefaultTransport}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			f, ok := rc.checkURL(context.Background(), srv.URL+tt.path)
+			if ok != (tt.msg != "") || f.Msg != tt.msg || f.Severity != tt.severity {
+				t.Errorf("got (%q, %v, %v), wanted %q with severity %v", f.Msg, f.Severity, ok, tt.msg, tt.severity)
+			}
+		})
+	}
+}
+
+func TestCheckURLNotPublic(t *testing.T) {
+	var requested bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+	}))
+	defer srv.Close()
+
+	f, ok := Reachability{}.checkURL(context.Background(), srv.URL)
+	if requested {
+		t.Error("a loopback address was requested")
+	}
+	if !ok || f.Msg != "The %s couldn't be reached" || f.ExtraMsg != "" {
+		t.Errorf("got (%q, %q, %v), wanted a generic finding", f.Msg, f.ExtraMsg, ok)
+	}
+}
+
+func TestCheckURLRedirectNotPublic(t *testing.T) {
+	var requested bool
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+	}))
+	defer internal.Close()
+
+	// The first request is made through a transport that allows
+	// loopback addresses, and the redirect uses the default one
+	rc := Reachability{Transport: redirectTransport{internal.URL}}
+	f, ok := rc.checkURL(context.Background(), "http://example.com/foo")
+	if requested {
+		t.Error("the redirect to a loopback address was followed")
+	}
+	if !ok || f.Msg != "The %s couldn't be reached" {
+		t.Errorf("got (%q, %v), wanted a finding", f.Msg, ok)
+	}
+}
+
+// redirectTransport redirects requests for example.com to
+// target, and sends other requests to the default transport
+type redirectTransport struct {
+	target string
+}
+
+func (rt redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != "example.com" {
+		return publicTransport.RoundTrip(req)
+	}
+
+	rec := httptest.NewRecorder()
+	http.Redirect(rec, req, rt.target, http.StatusFound)
+	return rec.Result(), nil
+}
+
+func TestCheckAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		ok   bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:443", true},
+		{"127.0.0.1:80", false},
+		{"127.1.2.3:80", false},
+		{"[::1]:80", false},
+		{"10.0.0.1:80", false},
+		{"172.16.0.1:80", false},
+		{"192.168.1.1:80", false},
+		{"[fd00::1]:80", false},
+		{"169.254.169.254:80", false},
+		{"[fe80::1]:80", false},
+		{"0.0.0.0:80", false},
+		{"[::]:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+		{"224.0.0.1:80", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if err := checkAddr("tcp", tt.addr, nil); (err == nil) != tt.ok {
+				t.Errorf("got %v, wanted ok = %v", err, tt.ok)
+			}
+		})
+	}
+}
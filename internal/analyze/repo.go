@@ -0,0 +1,91 @@
+package analyze
+
+import (
+	"bytes"
+	"io/fs"
+	pathpkg "path"
+	"strings"
+
+	"go.arsenm.dev/lure-repo-bot/internal/sandbox"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// RepoFindings checks a package against the other packages in
+// the repository. dir is the package's directory within repo,
+// which must contain the lure.sh script of every package.
+func RepoFindings(res *sandbox.Result, dir string, repo fs.FS) ([]Finding, error) {
+	name, ok := res.Runner.Vars["name"]
+	if !ok || name.String() == "" {
+		// A missing name is reported by AnalyzeScript
+		return nil, nil
+	}
+
+	scripts, err := fs.Glob(repo, "*/lure.sh")
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	for _, script := range scripts {
+		other := pathpkg.Dir(script)
+		if other == dir {
+			continue
+		}
+
+		data, err := fs.ReadFile(repo, script)
+		if err != nil {
+			return nil, err
+		}
+
+		// The other scripts aren't evaluated, so if their name
+		// can't be read, the directory name is used instead,
+		// since it's required to be the same
+		otherName, ok := scriptName(data)
+		if !ok {
+			otherName = other
+		}
+
+		if !strings.EqualFold(otherName, name.String()) {
+			continue
+		}
+
+		findings = append(findings, Finding{
+			ItemType: "variable",
+			ItemName: "name",
+			Msg:      "The %s is the same as the name of the existing package in '" + escapeMsg(other) + "'",
+			Rule:     RuleDuplicateName,
+		})
+	}
+
+	setPositions(findings, res.File)
+	return findings, nil
+}
+
+// scriptName returns the literal value of the last top-level
+// assignment to name in a script, without evaluating it
+func scriptName(data []byte) (string, bool) {
+	fl, err := syntax.NewParser().Parse(bytes.NewReader(data), "lure.sh")
+	if err != nil {
+		return "", false
+	}
+
+	var (
+		name  string
+		found bool
+	)
+	for _, stmt := range fl.Stmts {
+		call, ok := stmt.Cmd.(*syntax.CallExpr)
+		if !ok || len(call.Args) != 0 {
+			continue
+		}
+
+		for _, assign := range call.Assigns {
+			if assign.Name == nil || assign.Name.Value != "name" || assign.Value == nil {
+				continue
+			}
+			name, _, found = wordText(assign.Value)
+		}
+	}
+
+	return name, found && name != ""
+}
@@ -0,0 +1,68 @@
+package analyze
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"go.arsenm.dev/lure-repo-bot/internal/sandbox"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+func TestRepoFindings(t *testing.T) {
+	repo := fstest.MapFS{
+		"foo/lure.sh":     {Data: []byte("name=foo\nversion=1.0\n")},
+		"bar/lure.sh":     {Data: []byte("name='bar-git'\n")},
+		"baz/lure.sh":     {Data: []byte("name=\"$(echo baz)\"\n")},
+		"broken/lure.sh":  {Data: []byte("name=(\n")},
+		"qux/lure.sh":     {Data: []byte("name=old\nname=Qux2\n")},
+		"new-pkg/lure.sh": {Data: []byte("name=new-pkg\n")},
+	}
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"new-pkg", nil},
+		{"foo", []string{"foo"}},
+		{"FOO", []string{"foo"}},
+		{"bar-git", []string{"bar"}},
+		{"bar", nil},
+		{"baz", []string{"baz"}},
+		{"broken", []string{"broken"}},
+		{"qux2", []string{"qux"}},
+		{"old", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fl, err := syntax.NewParser().Parse(strings.NewReader("name="+tt.name+"\n"), "lure.sh")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := sandbox.Evaluate(context.Background(), fl, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			findings, err := RepoFindings(res, "new-pkg", repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got, want []string
+			for _, f := range findings {
+				got = append(got, f.Msg)
+			}
+			for _, dir := range tt.want {
+				want = append(want, "The %s is the same as the name of the existing package in '"+dir+"'")
+			}
+
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Errorf("got %q, wanted %q", got, want)
+			}
+		})
+	}
+}
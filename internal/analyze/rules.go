@@ -0,0 +1,61 @@
+package analyze
+
+// RulesURL is the URL of the rule documentation.
+// Each rule has a heading in the document, so the
+// rule ID can be used as the fragment.
+var RulesURL = "https://github.com/Elara6331/lure-repo-bot/blob/master/docs/rules.md"
+
+const (
+	RuleRequired          = "required"
+	RuleType              = "type"
+	RuleNumeric           = "numeric"
+	RuleURL               = "url"
+	RuleMaintainer        = "maintainer"
+	RuleArchitectures     = "architectures"
+	RuleLicense           = "license"
+	RuleSourceParams      = "source-params"
+	RuleChecksums         = "checksums"
+	RuleEvaluation        = "evaluation"
+	RuleCommands          = "top-level-commands"
+	RuleFileAccess        = "file-access"
+	RuleOverrides         = "overrides"
+	RuleDesc              = "desc"
+	RulePackageList       = "package-list"
+	RuleOptDeps           = "opt-deps"
+	RuleBuildVars         = "build-vars"
+	RuleBoolean           = "boolean"
+	RuleGroup             = "group"
+	RuleSection           = "section"
+	RulePriority          = "priority"
+	RuleScripts           = "scripts"
+	RuleScriptFiles       = "script-files"
+	RuleVersion           = "version"
+	RuleVersionSources    = "version-sources"
+	RuleName              = "name"
+	RuleSourceHTTPS       = "source-https"
+	RuleSourceHost        = "source-host"
+	RuleSourceStable      = "source-stable"
+	RuleChecksumSkip      = "checksum-skip"
+	RuleReachable         = "reachable"
+	RuleBackup            = "backup"
+	RuleDeprecatedLicense = "deprecated-license"
+	RuleDuplicateName     = "duplicate-name"
+
+	RulePkgdir         = "pkgdir"
+	RuleSudo           = "sudo"
+	RuleCdExit         = "cd-exit"
+	RuleUsrLocal       = "usr-local"
+	RuleRmUnset        = "rm-unset-var"
+	RulePackageNetwork = "package-network"
+
+	RuleQuoteVars    = "quote-vars"
+	RuleTestEquals   = "test-double-equals"
+	RuleUnusedVar    = "unused-var"
+	RuleUndefinedVar = "undefined-var"
+)
+
+// RuleDocURL returns the URL of the documentation
+// for the given rule ID
+func RuleDocURL(rule string) string {
+	return RulesURL + "#" + rule
+}
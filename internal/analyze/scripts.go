@@ -0,0 +1,119 @@
+package analyze
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// ScriptHooks are the keys that LURE accepts in the scripts map
+var ScriptHooks = []string{
+	"preinstall", "postinstall",
+	"preremove", "postremove",
+	"preupgrade", "postupgrade",
+	"pretrans", "posttrans",
+}
+
+// interactiveCmds are commands that wait for input from the
+// user, which hooks can't get, since they're run by the
+// package manager
+var interactiveCmds = []string{"read"}
+
+// checkScripts checks that every key in the scripts map is a hook,
+// and that the hook scripts exist in fsys and are valid. If fsys
+// is nil, the scripts aren't checked.
+func checkScripts(val any, name string, fsys fs.FS, findings *[]Finding) {
+	valMap, ok := mustBeMap(val, name, findings)
+	if !ok {
+		return
+	}
+
+	for key, file := range valMap {
+		if !slices.Contains(ScriptHooks, key) {
+			f := Finding{
+				ItemType: "key",
+				ItemName: name,
+				Index:    key,
+				Msg:      "The %s is not a known hook.",
+				ExtraMsg: "Valid hooks are: " + strings.Join(ScriptHooks, ", "),
+				Rule:     RuleScripts,
+			}
+			if similar := findSimilar(key, ScriptHooks); similar != "" {
+				f.Msg += " Did you mean '" + similar + "'?"
+			}
+			*findings = append(*findings, f)
+			continue
+		}
+
+		if fsys != nil {
+			checkScriptFile(fsys, name, key, file, findings)
+		}
+	}
+}
+
+// checkScriptFile checks the hook script at file, which
+// is relative to the package directory
+func checkScriptFile(fsys fs.FS, name, key, file string, findings *[]Finding) {
+	file = path.Clean(file)
+	escaped := escapeMsg(file)
+
+	add := func(msg, extra string) {
+		*findings = append(*findings, Finding{
+			ItemType: "element",
+			ItemName: name,
+			Index:    key,
+			Msg:      msg,
+			ExtraMsg: extra,
+			Rule:     RuleScriptFiles,
+		})
+	}
+
+	if !fs.ValidPath(file) {
+		add("The %s must be a path inside the package directory", "")
+		return
+	}
+
+	data, err := fs.ReadFile(fsys, file)
+	if errors.Is(err, fs.ErrNotExist) {
+		add("The %s refers to '"+escaped+"', which doesn't exist in the package directory", "")
+		return
+	} else if err != nil {
+		add("The %s refers to '"+escaped+"', which couldn't be read", err.Error())
+		return
+	}
+
+	fl, err := syntax.NewParser().Parse(bytes.NewReader(data), file)
+	if err != nil {
+		add("The %s refers to '"+escaped+"', which isn't a valid shell script", err.Error())
+		return
+	}
+
+	syntax.Walk(fl, func(node syntax.Node) bool {
+		var cmd string
+		switch node := node.(type) {
+		case *syntax.CallExpr:
+			if len(node.Args) > 0 && slices.Contains(interactiveCmds, node.Args[0].Lit()) {
+				cmd = node.Args[0].Lit()
+			}
+		case *syntax.ForClause:
+			// select is parsed as a loop, not a command
+			if node.Select {
+				cmd = "select"
+			}
+		}
+
+		if cmd != "" {
+			add(
+				"The %s refers to '"+escaped+"', which uses "+cmd+". Hooks are run by the package manager, so they can't wait for input.",
+				fmt.Sprintf("%s:%d:%d", file, node.Pos().Line(), node.Pos().Col()),
+			)
+		}
+		return true
+	})
+}
@@ -0,0 +1,72 @@
+package analyze
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCheckScripts(t *testing.T) {
+	fsys := fstest.MapFS{
+		"postinstall.sh": {Data: []byte("#!/bin/sh\nsystemctl daemon-reload\n")},
+		"hooks/pre.sh":   {Data: []byte("echo hi\n")},
+		"ask.sh":         {Data: []byte("read -r answer\n")},
+		"menu.sh":        {Data: []byte("echo start\nselect x in a b; do break; done\n")},
+		"broken.sh":      {Data: []byte("if true; then\n")},
+	}
+
+	tests := []struct {
+		name   string
+		val    any
+		fsys   fstest.MapFS
+		rule   string
+		msg    string
+		extra  string
+		wanted int
+	}{
+		{"valid", map[string]string{"postinstall": "postinstall.sh"}, fsys, "", "", "", 0},
+		{"subdirectory", map[string]string{"preinstall": "hooks/pre.sh"}, fsys, "", "", "", 0},
+		{"dot path", map[string]string{"preinstall": "./hooks/../hooks/pre.sh"}, fsys, "", "", "", 0},
+		{"no files", map[string]string{"postinstall": "missing.sh"}, nil, "", "", "", 0},
+		{"unknown hook", map[string]string{"postinstal": "postinstall.sh"}, fsys, RuleScripts, "Did you mean 'postinstall'?", "Valid hooks are", 1},
+		{"unrelated hook", map[string]string{"foo": "postinstall.sh"}, fsys, RuleScripts, "not a known hook.", "Valid hooks are", 1},
+		{"missing file", map[string]string{"postinstall": "missing.sh"}, fsys, RuleScriptFiles, "'missing.sh', which doesn't exist", "", 1},
+		{"outside package", map[string]string{"postinstall": "../other/postinstall.sh"}, fsys, RuleScriptFiles, "inside the package directory", "", 1},
+		{"absolute", map[string]string{"postinstall": "/etc/postinstall.sh"}, fsys, RuleScriptFiles, "inside the package directory", "", 1},
+		{"directory", map[string]string{"postinstall": "hooks"}, fsys, RuleScriptFiles, "couldn't be read", "", 1},
+		{"invalid script", map[string]string{"postinstall": "broken.sh"}, fsys, RuleScriptFiles, "isn't a valid shell script", "broken.sh:", 1},
+		{"percent in file", map[string]string{"postinstall": "100%d.sh"}, fsys, RuleScriptFiles, "'100%d.sh', which doesn't exist", "", 1},
+		{"read", map[string]string{"postinstall": "ask.sh"}, fsys, RuleScriptFiles, "uses read.", "ask.sh:1:1", 1},
+		{"select", map[string]string{"postinstall": "menu.sh"}, fsys, RuleScriptFiles, "uses select.", "menu.sh:2:1", 1},
+		{"string", "postinstall.sh", fsys, RuleType, "must be a map", "", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var findings []Finding
+			if tt.fsys == nil {
+				checkScripts(tt.val, "scripts", nil, &findings)
+			} else {
+				checkScripts(tt.val, "scripts", tt.fsys, &findings)
+			}
+
+			if len(findings) != tt.wanted {
+				t.Fatalf("got %d findings, wanted %d", len(findings), tt.wanted)
+			}
+			if tt.wanted == 0 {
+				return
+			}
+
+			f := findings[0]
+			if f.Rule != tt.rule {
+				t.Errorf("got rule %q, wanted %q", f.Rule, tt.rule)
+			}
+			if msg := f.Message(false); !strings.Contains(msg, tt.msg) {
+				t.Errorf("got message %q, wanted it to contain %q", msg, tt.msg)
+			}
+			if !strings.Contains(f.ExtraMsg, tt.extra) {
+				t.Errorf("got extra message %q, wanted it to contain %q", f.ExtraMsg, tt.extra)
+			}
+		})
+	}
+}
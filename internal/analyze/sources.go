@@ -0,0 +1,78 @@
+package analyze
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// httpsHosts are hosts that are known to support HTTPS,
+// so sources from them should never use plain HTTP
+var httpsHosts = []string{
+	"github.com", "gitlab.com", "codeberg.org", "bitbucket.org",
+	"sourceforge.net", "downloads.sourceforge.net", "sr.ht", "git.sr.ht",
+	"gitea.com", "pypi.org", "files.pythonhosted.org", "registry.npmjs.org",
+	"crates.io", "static.crates.io", "ftp.gnu.org", "download.gnome.org",
+	"download.kde.org", "kernel.org", "cdn.kernel.org", "dl.google.com",
+}
+
+// shortenerHosts are URL shorteners, which hide where
+// the source actually comes from and can be changed
+// to point somewhere else at any time
+var shortenerHosts = []string{
+	"bit.ly", "tinyurl.com", "goo.gl", "t.co", "is.gd", "ow.ly",
+	"buff.ly", "rebrand.ly", "cutt.ly", "shorturl.at", "git.io", "rb.gy",
+}
+
+// unstablePaths are parts of URL paths that point to
+// whatever the latest release or commit is, so their
+// content changes without the URL changing
+var unstablePaths = []string{
+	"/latest/download/",
+	"/releases/latest",
+	"/archive/refs/heads/",
+	"/archive/master.",
+	"/archive/main.",
+	"/-/archive/master/",
+	"/-/archive/main/",
+}
+
+// checkSourcePolicy checks that a source URL is secure
+// and points to a file that won't change
+func checkSourcePolicy(u *url.URL, name string, i int, findings *[]Finding) {
+	add := func(rule string, severity Severity, msg string) {
+		*findings = append(*findings, Finding{
+			ItemType: "element",
+			ItemName: name,
+			Index:    i,
+			Msg:      msg,
+			Rule:     rule,
+			Severity: severity,
+		})
+	}
+
+	host := strings.ToLower(u.Hostname())
+	scheme := strings.TrimPrefix(u.Scheme, "git+")
+	if scheme == "http" {
+		if slices.Contains(httpsHosts, strings.TrimPrefix(host, "www.")) {
+			add(RuleSourceHTTPS, SeverityError, "The %s uses plain HTTP, but "+escapeMsg(host)+" supports HTTPS. Use https:// instead.")
+		} else {
+			add(RuleSourceHTTPS, SeverityWarning, "The %s uses plain HTTP, so it can be modified in transit. Use https:// if the server supports it.")
+		}
+	}
+
+	if slices.Contains(shortenerHosts, strings.TrimPrefix(host, "www.")) {
+		add(RuleSourceHost, SeverityError, "The %s uses a URL shortener. Use the URL it redirects to instead.")
+	} else if net.ParseIP(host) != nil {
+		add(RuleSourceHost, SeverityWarning, "The %s uses an IP address instead of a domain name, so it will break if the server moves.")
+	}
+
+	for _, path := range unstablePaths {
+		if strings.Contains(u.Path, path) {
+			add(RuleSourceStable, SeverityWarning, "The %s points to the latest release or commit, so its contents will change, which breaks the checksum. Use a URL for a specific version instead.")
+			break
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package analyze
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCheckSourcePolicy(t *testing.T) {
+	type result struct {
+		rule     string
+		severity Severity
+	}
+
+	tests := []struct {
+		name   string
+		src    string
+		wanted []result
+	}{
+		{"https", "https://github.com/user/repo/archive/v1.0.tar.gz", nil},
+		{"git https", "git+https://github.com/user/repo.git", nil},
+		{"known https host", "http://github.com/user/repo/archive/v1.0.tar.gz", []result{{RuleSourceHTTPS, SeverityError}}},
+		{"known https host with www", "http://www.kernel.org/pub/linux.tar.xz", []result{{RuleSourceHTTPS, SeverityError}}},
+		{"known https host uppercase", "http://GitHub.com/user/repo.tar.gz", []result{{RuleSourceHTTPS, SeverityError}}},
+		{"unknown host", "http://example.com/foo.tar.gz", []result{{RuleSourceHTTPS, SeverityWarning}}},
+		{"git http", "git+http://example.com/foo.git", []result{{RuleSourceHTTPS, SeverityWarning}}},
+		{"shortener", "https://bit.ly/abc", []result{{RuleSourceHost, SeverityError}}},
+		{"shortener with www", "https://www.tinyurl.com/abc", []result{{RuleSourceHost, SeverityError}}},
+		{"ipv4", "https://203.0.113.5/foo.tar.gz", []result{{RuleSourceHost, SeverityWarning}}},
+		{"ipv6", "https://[2001:db8::1]/foo.tar.gz", []result{{RuleSourceHost, SeverityWarning}}},
+		{"latest download", "https://github.com/user/repo/releases/latest/download/foo.tar.gz", []result{{RuleSourceStable, SeverityWarning}}},
+		{"branch archive", "https://github.com/user/repo/archive/refs/heads/main.tar.gz", []result{{RuleSourceStable, SeverityWarning}}},
+		{"master archive", "https://github.com/user/repo/archive/master.zip", []result{{RuleSourceStable, SeverityWarning}}},
+		{"gitlab branch archive", "https://gitlab.com/user/repo/-/archive/main/repo-main.tar.gz", []result{{RuleSourceStable, SeverityWarning}}},
+		{"tag archive", "https://github.com/user/repo/archive/refs/tags/v1.0.tar.gz", nil},
+		{"several", "http://198.51.100.7/releases/latest", []result{
+			{RuleSourceHTTPS, SeverityWarning},
+			{RuleSourceHost, SeverityWarning},
+			{RuleSourceStable, SeverityWarning},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var findings []Finding
+			checkSourcePolicy(u, "sources", 2, &findings)
+
+			if len(findings) != len(tt.wanted) {
+				t.Fatalf("got %d findings, wanted %d", len(findings), len(tt.wanted))
+			}
+
+			for i, f := range findings {
+				if f.Rule != tt.wanted[i].rule || f.Severity != tt.wanted[i].severity {
+					t.Errorf("got %s %v, wanted %s %v", f.Rule, f.Severity, tt.wanted[i].rule, tt.wanted[i].severity)
+				}
+				if f.Index != 2 || !strings.HasPrefix(f.Message(false), "The sources[2] element") {
+					t.Errorf("got message %q, wanted it to be about sources[2]", f.Message(false))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package analyze
+
+import "strings"
+
+// ScriptVars contains the names of the variables that
+// LURE reads from scripts. Some of them contain
+// underscores, so they can't be found by cutting a
+// name at its first underscore.
+var ScriptVars = []string{
+	"name",
+	"version",
+	"release",
+	"epoch",
+	"desc",
+	"homepage",
+	"maintainer",
+	"architectures",
+	"license",
+	"provides",
+	"conflicts",
+	"deps",
+	"build_deps",
+	"build_vars",
+	"opt_deps",
+	"replaces",
+	"sources",
+	"checksums",
+	"backup",
+	"scripts",
+	"auto_req",
+	"auto_prov",
+	"firmware",
+	"group",
+	"section",
+	"priority",
+}
+
+// ParseVarName splits a variable name into the LURE variable
+// it refers to and its override suffix, if any. For example,
+// build_deps_ubuntu_arm64 is split into build_deps and
+// ubuntu_arm64. ok is false if name isn't a LURE variable.
+func ParseVarName(name string) (base, suffix string, ok bool) {
+	for _, v := range ScriptVars {
+		if name == v {
+			return v, "", true
+		}
+
+		// If more than one variable matches, the longest one is
+		// used, so build_deps_x is parsed as build_deps and not
+		// as an override of a variable called build.
+		if strings.HasPrefix(name, v+"_") && len(v) > len(base) {
+			base, suffix, ok = v, name[len(v)+1:], true
+		}
+	}
+	return base, suffix, ok
+}
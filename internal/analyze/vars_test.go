@@ -0,0 +1,65 @@
+package analyze
+
+import "testing"
+
+func TestParseVarName(t *testing.T) {
+	tests := []struct {
+		name   string
+		base   string
+		suffix string
+		ok     bool
+	}{
+		{"build_deps", "build_deps", "", true},
+		{"build_deps_amd64", "build_deps", "amd64", true},
+		{"build_deps_ubuntu", "build_deps", "ubuntu", true},
+		{"build_deps_ubuntu_arm64", "build_deps", "ubuntu_arm64", true},
+		{"build_vars", "build_vars", "", true},
+		{"build_vars_ubuntu", "build_vars", "ubuntu", true},
+		{"build_vars_arm64", "build_vars", "arm64", true},
+		{"opt_deps", "opt_deps", "", true},
+		{"opt_deps_arch_arm64", "opt_deps", "arch_arm64", true},
+		{"opt_deps_fedora", "opt_deps", "fedora", true},
+		{"checksums_amd64", "checksums", "amd64", true},
+		{"auto_req", "auto_req", "", true},
+		{"auto_req_debian", "auto_req", "debian", true},
+		{"auto_prov_386", "auto_prov", "386", true},
+		{"deps_debian_amd64", "deps", "debian_amd64", true},
+		{"version_major", "version", "major", true},
+		{"build", "", "", false},
+		{"build_dep", "", "", false},
+		{"builddeps", "", "", false},
+		{"depsx", "", "", false},
+		{"_deps", "", "", false},
+		{"opt", "", "", false},
+		{"auto", "", "", false},
+		{"srcdir", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, suffix, ok := ParseVarName(tt.name)
+			if base != tt.base || suffix != tt.suffix || ok != tt.ok {
+				t.Errorf("got (%q, %q, %v), wanted (%q, %q, %v)", base, suffix, ok, tt.base, tt.suffix, tt.ok)
+			}
+		})
+	}
+}
+
+// TestParseVarNameOverrides checks every LURE variable
+// with every kind of override suffix
+func TestParseVarNameOverrides(t *testing.T) {
+	suffixes := []string{"", "ubuntu", "amd64", "arm64", "arch_arm64", "debian_386"}
+	for _, v := range ScriptVars {
+		for _, suffix := range suffixes {
+			name := v
+			if suffix != "" {
+				name += "_" + suffix
+			}
+
+			base, gotSuffix, ok := ParseVarName(name)
+			if !ok || base != v || gotSuffix != suffix {
+				t.Errorf("%s: got (%q, %q, %v), wanted (%q, %q, true)", name, base, gotSuffix, ok, v, suffix)
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package analyze
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"go.arsenm.dev/lure-repo-bot/internal/version"
+	"mvdan.cc/sh/v3/expand"
+)
+
+// srcVersionRegex matches strings in source URLs that look like versions
+var srcVersionRegex = regexp.MustCompile(`\d+(?:\.\d+)+`)
+
+// checkVersion checks the format of the version variable.
+// release is the value of the release variable, if it's set.
+func checkVersion(val any, name, release string, findings *[]Finding) (string, bool) {
+	ver, ok := mustBeStr(val, name, findings)
+	if !ok {
+		return "", false
+	}
+
+	add := func(msg string) {
+		*findings = append(*findings, Finding{
+			ItemType: "variable",
+			ItemName: name,
+			Msg:      msg,
+			Rule:     RuleVersion,
+		})
+	}
+
+	switch {
+	case strings.IndexFunc(ver, unicode.IsSpace) != -1:
+		add("The %s must not contain spaces")
+	case len(ver) > 1 && (ver[0] == 'v' || ver[0] == 'V') && unicode.IsDigit(rune(ver[1])):
+		add("The %s must not start with '" + ver[:1] + "'. Use '" + escapeMsg(ver[1:]) + "' instead, and add the prefix in the sources URLs.")
+	case release != "" && embedsRelease(ver, release):
+		add("The %s must not contain the release. Set it using the release variable instead.")
+	case strings.Contains(ver, "-"):
+		add("The %s must not contain hyphens, since they're used to separate the version from the release in deb and rpm packages. Use '" + escapeMsg(strings.ReplaceAll(ver, "-", ".")) + "' instead.")
+	case strings.Contains(ver, ":"):
+		add("The %s must not contain colons, since they're used to separate the epoch from the version. Set the epoch using the epoch variable instead.")
+	}
+
+	return ver, true
+}
+
+// embedsRelease checks whether ver ends with
+// the release, like 1.2.3-1 or 1.2.3_r1
+func embedsRelease(ver, release string) bool {
+	for _, sep := range []string{"-", "_", "-r", "_r", ".r"} {
+		if strings.HasSuffix(ver, sep+release) {
+			return true
+		}
+	}
+	return false
+}
+
+// versionSourcesFindings warns if the version doesn't appear in
+// any of the sources, which usually means that one of them was
+// forgotten when the version was updated.
+func versionSourcesFindings(ver string, vars map[string]expand.Variable) []Finding {
+	if ver == "" {
+		return nil
+	}
+
+	variants := []string{
+		ver,
+		strings.ReplaceAll(ver, ".", "_"),
+		strings.ReplaceAll(ver, ".", "-"),
+	}
+
+	checked := false
+	// newest is the newest version that appears in the sources
+	newest := ""
+	for name, v := range vars {
+		if base, _, _ := ParseVarName(name); base != "sources" {
+			continue
+		}
+
+		for _, src := range v.List {
+			// Git sources without a tag use the latest
+			// commit, so the version isn't in the URL
+			if strings.HasPrefix(src, "git+") && !hasParam(src, "~tag") {
+				continue
+			}
+			checked = true
+
+			for _, variant := range variants {
+				if strings.Contains(src, variant) {
+					return nil
+				}
+			}
+
+			for _, srcVer := range srcVersionRegex.FindAllString(srcPath(src), -1) {
+				if newest == "" || version.Compare(srcVer, newest) > 0 {
+					newest = srcVer
+				}
+			}
+		}
+	}
+
+	if !checked {
+		return nil
+	}
+
+	f := Finding{
+		ItemType: "variable",
+		ItemName: "version",
+		Msg:      "The %s doesn't appear in any of the sources. Make sure they were updated for this version.",
+		Rule:     RuleVersionSources,
+		Severity: SeverityWarning,
+	}
+
+	switch c := version.Compare(newest, ver); {
+	case newest == "":
+	case c > 0:
+		f.ExtraMsg = "The sources contain version " + newest + ", which is newer than " + ver + ", so the version may not have been updated."
+	case c < 0:
+		f.ExtraMsg = "The sources contain version " + newest + ", which is older than " + ver + ", so they may not have been updated."
+	}
+
+	return []Finding{f}
+}
+
+// srcPath returns the path of a source URL, so that
+// IP addresses in the host aren't mistaken for versions
+func srcPath(src string) string {
+	u, err := url.Parse(strings.TrimPrefix(src, "git+"))
+	if err != nil {
+		return src
+	}
+	return u.Path
+}
+
+func hasParam(src, param string) bool {
+	u, err := url.Parse(src)
+	return err == nil && u.Query().Has(param)
+}
@@ -0,0 +1,49 @@
+package analyze
+
+import (
+	"testing"
+
+	"mvdan.cc/sh/v3/expand"
+)
+
+func TestVersionSourcesFindings(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  string
+		sources  []string
+		found    bool
+		extraMsg string
+	}{
+		{"found", "1.2.3", []string{"https://example.com/foo-1.2.3.tar.gz"}, false, ""},
+		{"underscores", "1.2.3", []string{"https://example.com/foo_1_2_3.tar.gz"}, false, ""},
+		{"untagged git", "1.2.3", []string{"git+https://example.com/foo.git"}, false, ""},
+		{"no version", "1.2.3", []string{"https://example.com/foo.tar.gz"}, true, ""},
+		{
+			"older", "1.2.3", []string{"https://example.com/foo-1.2.2.tar.gz"}, true,
+			"The sources contain version 1.2.2, which is older than 1.2.3, so they may not have been updated.",
+		},
+		{
+			"newer", "1.2.3", []string{"https://example.com/v1.10.0/foo-1.10.0.tar.gz"}, true,
+			"The sources contain version 1.10.0, which is newer than 1.2.3, so the version may not have been updated.",
+		},
+		{
+			"ip host", "1.2.3", []string{"https://10.0.0.1/foo.tar.gz"}, true, "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars := map[string]expand.Variable{
+				"sources": {Kind: expand.Indexed, List: tt.sources},
+			}
+
+			findings := versionSourcesFindings(tt.version, vars)
+			if found := len(findings) > 0; found != tt.found {
+				t.Fatalf("got findings %v, wanted %v", findings, tt.found)
+			}
+			if tt.found && findings[0].ExtraMsg != tt.extraMsg {
+				t.Errorf("got extra message %q, wanted %q", findings[0].ExtraMsg, tt.extraMsg)
+			}
+		})
+	}
+}
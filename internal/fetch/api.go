@@ -0,0 +1,111 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/google/go-github/v48/github"
+	"go.arsenm.dev/lure-repo-bot/internal/types"
+)
+
+const (
+	// DefaultMaxFiles is the default value of API.MaxFiles
+	DefaultMaxFiles = 50
+	// DefaultMaxSize is the default value of API.MaxSize
+	DefaultMaxSize = 1 << 20
+)
+
+// ErrTooLarge is returned by API.Fetch when the requested
+// directories contain too many files, or files that are
+// too large, to be downloaded one at a time
+var ErrTooLarge = errors.New("fetch: too many files to download using the API")
+
+// API fetches files using the GitHub git data API. The tree of
+// the PR's head commit is listed with a single request, and then
+// only the files in the directories containing the requested
+// files are downloaded.
+type API struct {
+	Client *github.Client
+	// MaxFiles is the maximum number of files to download.
+	// If it's zero, DefaultMaxFiles is used.
+	MaxFiles int
+	// MaxSize is the maximum total size of the files to
+	// download, in bytes. If it's zero, DefaultMaxSize is used.
+	MaxSize int64
+}
+
+func (a API) Fetch(ctx context.Context, pr *types.PullRequest, paths []string) (fs.FS, error) {
+	head := pr.Head
+
+	tree, _, err := a.Client.Git.GetTree(ctx, head.Repo.Owner.Login, head.Repo.Name, head.Sha, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// A truncated tree might be missing some of the files
+	if tree.GetTruncated() {
+		return nil, ErrTooLarge
+	}
+
+	blobs, err := a.findBlobs(tree, dirs(paths))
+	if err != nil {
+		return nil, err
+	}
+
+	out := memFS{}
+	for _, blob := range blobs {
+		data, _, err := a.Client.Git.GetBlobRaw(ctx, head.Repo.Owner.Login, head.Repo.Name, blob.GetSHA())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", blob.GetPath(), err)
+		}
+		addFile(out, blob.GetPath(), data)
+	}
+	return out, nil
+}
+
+// findBlobs returns the entries of the files inside dirs,
+// including the ones in subdirectories. It returns ErrTooLarge
+// if there are more files than the limits allow.
+func (a API) findBlobs(tree *github.Tree, dirs []string) ([]*github.TreeEntry, error) {
+	maxFiles, maxSize := a.MaxFiles, a.MaxSize
+	if maxFiles == 0 {
+		maxFiles = DefaultMaxFiles
+	}
+	if maxSize == 0 {
+		maxSize = DefaultMaxSize
+	}
+
+	var (
+		out  []*github.TreeEntry
+		size int64
+	)
+	for _, dir := range dirs {
+		found := false
+		for _, entry := range tree.Entries {
+			if dir != "." && !strings.HasPrefix(entry.GetPath(), dir+"/") {
+				continue
+			}
+			found = true
+
+			// Submodules aren't blobs, and symlinks are
+			// skipped since scripts can't follow them
+			if entry.GetType() != "blob" || entry.GetMode() == "120000" {
+				continue
+			}
+
+			out = append(out, entry)
+			size += int64(entry.GetSize())
+			if len(out) > maxFiles || size > maxSize {
+				return nil, ErrTooLarge
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("%s: directory not found", dir)
+		}
+	}
+	return out, nil
+}
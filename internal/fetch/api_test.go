@@ -0,0 +1,199 @@
+package fetch
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-github/v48/github"
+	"go.arsenm.dev/lure-repo-bot/internal/types"
+)
+
+// gitDataServer serves files using the GitHub git data API.
+// Requests for the tree of any commit other than sha fail.
+// Paths in links are served as symlinks to their values.
+// The returned counter is the number of blobs downloaded.
+func gitDataServer(t *testing.T, sha string, files, links map[string]string, truncated bool) (*github.Client, *int32) {
+	blobs := map[string]string{}
+	blobSHA := func(content string) string {
+		sum := sha1.Sum([]byte(content))
+		blobs[hex.EncodeToString(sum[:])] = content
+		return hex.EncodeToString(sum[:])
+	}
+
+	var entries []map[string]any
+	dirs := map[string]bool{}
+	addEntry := func(p, mode, content string) {
+		entries = append(entries, map[string]any{
+			"path": p,
+			"mode": mode,
+			"type": "blob",
+			"sha":  blobSHA(content),
+			"size": len(content),
+		})
+
+		for dir := path.Dir(p); dir != "." && !dirs[dir]; dir = path.Dir(dir) {
+			dirs[dir] = true
+			entries = append(entries, map[string]any{"path": dir, "mode": "040000", "type": "tree"})
+		}
+	}
+	for p, content := range files {
+		addEntry(p, "100644", content)
+	}
+	for p, target := range links {
+		addEntry(p, "120000", target)
+	}
+
+	var downloaded int32
+	srv := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		const prefix = "/repos/user/lure-repo/git/"
+		p := strings.TrimPrefix(req.URL.Path, prefix)
+
+		switch {
+		case p == "trees/"+sha && req.URL.Query().Get("recursive") == "1":
+			json.NewEncoder(res).Encode(map[string]any{
+				"sha":       sha,
+				"tree":      entries,
+				"truncated": truncated,
+			})
+		case strings.HasPrefix(p, "blobs/"):
+			content, ok := blobs[strings.TrimPrefix(p, "blobs/")]
+			if !ok || req.Header.Get("Accept") != "application/vnd.github.v3.raw" {
+				http.NotFound(res, req)
+				return
+			}
+			atomic.AddInt32(&downloaded, 1)
+			res.Write([]byte(content))
+		default:
+			http.NotFound(res, req)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client := github.NewClient(srv.Client())
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+	return client, &downloaded
+}
+
+func apiPR(sha string) *types.PullRequest {
+	pr := &types.PullRequest{Number: 1}
+	pr.Head.Sha = sha
+	pr.Head.Repo.Name = "lure-repo"
+	pr.Head.Repo.Owner.Login = "user"
+	return pr
+}
+
+func TestAPIFetch(t *testing.T) {
+	files := map[string]string{
+		"foo/lure.sh":           "name=foo\n",
+		"foo/patches/fix.patch": "patch",
+		"bar/lure.sh":           "name=bar\n",
+		"foobar/lure.sh":        "name=foobar\n",
+	}
+	links := map[string]string{"foo/link": "/etc/passwd"}
+	client, downloaded := gitDataServer(t, "abc123", files, links, false)
+
+	fsys, err := API{Client: client}.Fetch(context.Background(), apiPR("abc123"), []string{"foo/lure.sh"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The whole package directory is downloaded, including
+	// subdirectories, but not the other packages or symlinks
+	got := strings.Join(fsPaths(t, fsys), ",")
+	if got != "foo/lure.sh,foo/patches/fix.patch" {
+		t.Errorf("got %s, wanted foo/lure.sh,foo/patches/fix.patch", got)
+	}
+
+	if n := atomic.LoadInt32(downloaded); n != 2 {
+		t.Errorf("got %d downloaded blobs, wanted 2", n)
+	}
+
+	for file, want := range map[string]string{
+		"foo/lure.sh":           "name=foo\n",
+		"foo/patches/fix.patch": "patch",
+	} {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s: got %q, wanted %q", file, data, want)
+		}
+	}
+}
+
+func TestAPIFetchErrors(t *testing.T) {
+	files := map[string]string{
+		"foo/lure.sh":           "name=foo\n",
+		"foo/patches/fix.patch": "patch",
+	}
+	client, downloaded := gitDataServer(t, "abc123", files, nil, false)
+	truncatedClient, _ := gitDataServer(t, "abc123", files, nil, true)
+
+	tests := []struct {
+		name   string
+		api    API
+		sha    string
+		paths  []string
+		tooBig bool
+	}{
+		{"missing directory", API{Client: client}, "abc123", []string{"bar/lure.sh"}, false},
+		{"wrong ref", API{Client: client}, "def456", []string{"foo/lure.sh"}, false},
+		{"too many files", API{Client: client, MaxFiles: 1}, "abc123", []string{"foo/lure.sh"}, true},
+		{"too large", API{Client: client, MaxSize: 10}, "abc123", []string{"foo/lure.sh"}, true},
+		{"whole repository", API{Client: client, MaxFiles: 1}, "abc123", []string{"lure.sh"}, true},
+		{"truncated tree", API{Client: truncatedClient}, "abc123", []string{"foo/lure.sh"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.api.Fetch(context.Background(), apiPR(tt.sha), tt.paths)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if errors.Is(err, ErrTooLarge) != tt.tooBig {
+				t.Errorf("got %v, wanted ErrTooLarge: %t", err, tt.tooBig)
+			}
+		})
+	}
+
+	// The limits are checked before anything is downloaded
+	if n := atomic.LoadInt32(downloaded); n != 0 {
+		t.Errorf("got %d downloaded blobs, wanted none", n)
+	}
+}
+
+func TestAPIFetchFallback(t *testing.T) {
+	client, _ := gitDataServer(t, "abc123", map[string]string{"foo/lure.sh": "name=foo\n"}, nil, false)
+
+	want := memFS{}
+	addFile(want, "foo/lure.sh", []byte("name=foo\n"))
+	fetcher := Chain(API{Client: client, MaxSize: 1}, staticFetcher{want})
+
+	fsys, err := fetcher.Fetch(context.Background(), apiPR("abc123"), []string{"foo/lure.sh"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := fsys.(memFS); !ok || got["foo/lure.sh"] != want["foo/lure.sh"] {
+		t.Errorf("got %v, wanted the result of the next fetcher", fsys)
+	}
+}
+
+type staticFetcher struct {
+	fsys fs.FS
+}
+
+func (s staticFetcher) Fetch(context.Context, *types.PullRequest, []string) (fs.FS, error) {
+	return s.fsys, nil
+}
@@ -0,0 +1,75 @@
+// Package fetch provides strategies for retrieving
+// the files changed in a pull request.
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"testing/fstest"
+
+	"go.arsenm.dev/lure-repo-bot/internal/types"
+)
+
+// Fetcher retrieves files from the head commit of a PR
+type Fetcher interface {
+	// Fetch returns a filesystem rooted at the top of the
+	// repository containing at least the requested paths and
+	// all the other files in their directories, as they exist
+	// in the PR's head commit.
+	Fetch(ctx context.Context, pr *types.PullRequest, paths []string) (fs.FS, error)
+}
+
+// Chain returns a Fetcher that tries each of the given fetchers
+// in order, returning the result of the first one that succeeds.
+func Chain(fetchers ...Fetcher) Fetcher {
+	return chain(fetchers)
+}
+
+type chain []Fetcher
+
+func (c chain) Fetch(ctx context.Context, pr *types.PullRequest, paths []string) (fs.FS, error) {
+	var errs []string
+	for _, f := range c {
+		fsys, err := f.Fetch(ctx, pr, paths)
+		if err == nil {
+			return fsys, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		errs = append(errs, fmt.Sprintf("%T: %s", f, err))
+	}
+
+	if len(errs) == 0 {
+		return nil, errors.New("fetch: no fetchers provided")
+	}
+
+	return nil, fmt.Errorf("fetch: all fetchers failed: %s", strings.Join(errs, "; "))
+}
+
+// memFS is an in-memory filesystem containing fetched files
+type memFS = fstest.MapFS
+
+func addFile(fsys memFS, path string, data []byte) {
+	fsys[path] = &fstest.MapFile{Data: data, Mode: 0o644}
+}
+
+// dirs returns the unique directories containing paths
+func dirs(paths []string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, p := range paths {
+		dir := path.Dir(p)
+		if !seen[dir] {
+			seen[dir] = true
+			out = append(out, dir)
+		}
+	}
+	return out
+}
@@ -0,0 +1,127 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"go.arsenm.dev/lure-repo-bot/internal/types"
+)
+
+// Shallow fetches only the PR's head commit from the base
+// repository into memory, using the refs/pull/<n>/head ref
+// that GitHub maintains for every PR.
+type Shallow struct{}
+
+func (Shallow) Fetch(ctx context.Context, pr *types.PullRequest, paths []string) (fs.FS, error) {
+	r, err := git.Init(memory.NewStorage(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := r.CreateRemote(&config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{pr.Base.Repo.CloneURL},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ref := fmt.Sprintf("refs/pull/%d/head", pr.Number)
+	err = remote.FetchContext(ctx, &git.FetchOptions{
+		RefSpecs: []config.RefSpec{config.RefSpec("+" + ref + ":" + ref)},
+		Depth:    1,
+		Tags:     git.NoTags,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return nil, err
+	}
+
+	tree, err := commitTree(r, pr.Head.Sha)
+	if err != nil {
+		return nil, err
+	}
+
+	return readPaths(tree, paths)
+}
+
+func commitTree(r *git.Repository, sha string) (*object.Tree, error) {
+	co, err := r.CommitObject(plumbing.NewHash(sha))
+	if err != nil {
+		return nil, err
+	}
+
+	return co.Tree()
+}
+
+// readPaths reads the directories containing the given paths from a git tree
+func readPaths(tree *object.Tree, paths []string) (memFS, error) {
+	out := memFS{}
+	for _, dir := range dirs(paths) {
+		subtree := tree
+		if dir != "." {
+			var err error
+			subtree, err = tree.Tree(dir)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", dir, err)
+			}
+		}
+
+		err := subtree.Files().ForEach(func(f *object.File) error {
+			f.Name = path.Join(dir, f.Name)
+			return addTreeFile(out, f)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// readScripts adds the lure.sh script of every package in tree to
+// fsys. The trees are listed without reading any of the other files.
+func readScripts(tree *object.Tree, fsys memFS) error {
+	for _, entry := range tree.Entries {
+		if entry.Mode != filemode.Dir {
+			continue
+		}
+
+		f, err := tree.File(path.Join(entry.Name, "lure.sh"))
+		if errors.Is(err, object.ErrFileNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		err = addTreeFile(fsys, f)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addTreeFile(fsys memFS, f *object.File) error {
+	r, err := f.Reader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	addFile(fsys, f.Name, data)
+	return nil
+}
@@ -0,0 +1,142 @@
+package fetch
+
+import (
+	"context"
+	"io/fs"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// headTree returns the tree of the test repo's current commit
+func (tr *testRepo) headTree() *object.Tree {
+	head, err := tr.repo.Head()
+	if err != nil {
+		tr.t.Fatal(err)
+	}
+
+	tree, err := commitTree(tr.repo, head.Hash().String())
+	if err != nil {
+		tr.t.Fatal(err)
+	}
+	return tree
+}
+
+// fsPaths returns the paths of all the files in fsys, sorted
+func fsPaths(t *testing.T, fsys fs.FS) []string {
+	var out []string
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			out = append(out, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestShallowFetch(t *testing.T) {
+	tr := newTestRepo(t)
+	tr.commit("foo/foo.patch", "patch")
+	pr := tr.pr(1, "bar")
+
+	fsys, err := Shallow{}.Fetch(context.Background(), pr, []string{"bar/lure.sh"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the directories of the requested paths are read
+	got := strings.Join(fsPaths(t, fsys), ",")
+	if got != "bar/lure.sh" {
+		t.Errorf("got %s, wanted bar/lure.sh", got)
+	}
+
+	data, err := fs.ReadFile(fsys, "bar/lure.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "name=bar\n" {
+		t.Errorf("got %q, wanted %q", data, "name=bar\n")
+	}
+}
+
+func TestShallowFetchMissingCommit(t *testing.T) {
+	tr := newTestRepo(t)
+	pr := tr.pr(1, "bar")
+	pr.Head.Sha = plumbing.ZeroHash.String()
+
+	_, err := Shallow{}.Fetch(context.Background(), pr, []string{"bar/lure.sh"})
+	if err == nil {
+		t.Error("expected an error for a commit that isn't in the PR's ref")
+	}
+}
+
+func TestReadPaths(t *testing.T) {
+	tr := newTestRepo(t)
+	tr.commit("foo/patches/fix.patch", "patch")
+	tr.commit("foo/foo.desktop", "desktop")
+	tr.commit("bar/lure.sh", "name=bar\n")
+	tr.commit("README.md", "readme")
+	tree := tr.headTree()
+
+	tests := []struct {
+		name   string
+		paths  []string
+		wanted []string
+	}{
+		{"package", []string{"foo/lure.sh"}, []string{"foo/foo.desktop", "foo/lure.sh", "foo/patches/fix.patch"}},
+		{"subdirectory", []string{"foo/patches/fix.patch"}, []string{"foo/patches/fix.patch"}},
+		{"two packages", []string{"foo/lure.sh", "bar/lure.sh", "bar/lure.sh"}, []string{"bar/lure.sh", "foo/foo.desktop", "foo/lure.sh", "foo/patches/fix.patch"}},
+		{"root", []string{"README.md"}, []string{"README.md", "bar/lure.sh", "foo/foo.desktop", "foo/lure.sh", "foo/patches/fix.patch"}},
+		{"none", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, err := readPaths(tree, tt.paths)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, wanted := strings.Join(fsPaths(t, fsys), ","), strings.Join(tt.wanted, ",")
+			if got != wanted {
+				t.Errorf("got %s, wanted %s", got, wanted)
+			}
+		})
+	}
+
+	_, err := readPaths(tree, []string{"missing/lure.sh"})
+	if err == nil || !strings.HasPrefix(err.Error(), "missing: ") {
+		t.Errorf("got error %v, wanted an error about the missing directory", err)
+	}
+}
+
+func TestReadScripts(t *testing.T) {
+	tr := newTestRepo(t)
+	tr.commit("foo/foo.patch", "patch")
+	tr.commit("bar/lure.sh", "name=bar\n")
+	tr.commit("docs/README.md", "docs")
+	tr.commit("baz/sub/lure.sh", "name=baz\n")
+	tr.commit("lure.sh", "name=root\n")
+
+	fsys := memFS{}
+	err := readScripts(tr.headTree(), fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the scripts directly inside each package directory are read
+	got := strings.Join(fsPaths(t, fsys), ",")
+	if got != "bar/lure.sh,foo/lure.sh" {
+		t.Errorf("got %s, wanted bar/lure.sh,foo/lure.sh", got)
+	}
+}
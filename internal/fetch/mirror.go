@@ -0,0 +1,314 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"go.arsenm.dev/lure-repo-bot/internal/types"
+)
+
+// mirrorRefPrefix is the prefix of the refs that
+// PR heads are fetched into within the mirror.
+const mirrorRefPrefix = "refs/lure-bot/pull/"
+
+// refsFile is the name of the file inside the mirror
+// that stores the last time each PR ref was fetched.
+const refsFile = "lure-bot-refs.json"
+
+// Mirror keeps a persistent bare mirror of the base repository
+// on disk and fetches each PR's head ref into it, so that only
+// new objects have to be downloaded for each job. Along with the
+// requested paths, the lure.sh script of every package in the PR's
+// head commit is returned, so it's suitable for checks that
+// compare a package to the rest of the repository.
+//
+// A Mirror is safe for concurrent use, and the mirror directory
+// is reused across restarts.
+type Mirror struct {
+	// Dir is the directory containing the bare mirror
+	Dir string
+	// MaxAge is how long a PR ref is kept after it was last
+	// fetched before it's removed by GC. Defaults to a week.
+	MaxAge time.Duration
+
+	// gcMtx is held for reading by fetches and for writing by
+	// GC, so that objects aren't pruned while they're being used
+	gcMtx sync.RWMutex
+	// mtx guards the fields below
+	mtx sync.Mutex
+	// opened is true once the mirror has been initialized.
+	// The repository itself isn't kept open, since go-git's
+	// storage isn't safe for concurrent use, and its cached
+	// packfiles are invalidated by GC.
+	opened  bool
+	refs    map[string]time.Time
+	prLocks map[string]*sync.Mutex
+}
+
+func (m *Mirror) Fetch(ctx context.Context, pr *types.PullRequest, paths []string) (fs.FS, error) {
+	m.gcMtx.RLock()
+	defer m.gcMtx.RUnlock()
+
+	prRef := fmt.Sprintf("%s%d", mirrorRefPrefix, pr.Number)
+
+	m.mtx.Lock()
+	err := m.open(pr.Base.Repo.CloneURL)
+	if err != nil {
+		m.mtx.Unlock()
+		return nil, err
+	}
+	prLock := m.prLock(prRef)
+	m.mtx.Unlock()
+
+	// Only fetches of the same PR are serialized, so
+	// different PRs can be fetched at the same time
+	prLock.Lock()
+	defer prLock.Unlock()
+
+	// go-git's storage isn't safe for concurrent use, so each
+	// fetch opens the mirror separately. Packfiles are written
+	// atomically and refs are locked on disk.
+	repo, err := git.PlainOpen(m.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	baseRef := plumbing.NewBranchReferenceName(pr.Base.Ref)
+	err = repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: "origin",
+		RefSpecs: []config.RefSpec{
+			config.RefSpec(fmt.Sprintf("+refs/pull/%d/head:%s", pr.Number, prRef)),
+			config.RefSpec("+" + baseRef + ":" + baseRef),
+		},
+		Tags: git.NoTags,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return nil, err
+	}
+
+	m.mtx.Lock()
+	m.refs[prRef] = time.Now()
+	err = m.saveRefs()
+	m.mtx.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := commitTree(repo, pr.Head.Sha)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := readPaths(tree, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	err = readScripts(tree, out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// GC removes PR refs that haven't been fetched within MaxAge,
+// and then removes any objects that are no longer reachable.
+func (m *Mirror) GC() error {
+	m.gcMtx.Lock()
+	defer m.gcMtx.Unlock()
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	// The repository is opened again for every GC, since the
+	// packfiles that were cached by the last one are removed
+	// when it repacks the objects
+	repo, err := git.PlainOpen(m.Dir)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		// The mirror hasn't been created yet,
+		// so there's nothing to collect
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if m.refs == nil {
+		err = m.loadRefs()
+		if err != nil {
+			return err
+		}
+	}
+
+	maxAge := m.MaxAge
+	if maxAge == 0 {
+		maxAge = 7 * 24 * time.Hour
+	}
+
+	iter, err := repo.References()
+	if err != nil {
+		return err
+	}
+
+	var stale []plumbing.ReferenceName
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().String()
+		if !strings.HasPrefix(name, mirrorRefPrefix) {
+			return nil
+		}
+
+		// Refs that aren't in the refs file were fetched by a
+		// process that didn't get to save it, so they're stale.
+		if fetched, ok := m.refs[name]; !ok || time.Since(fetched) > maxAge {
+			stale = append(stale, ref.Name())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(stale) == 0 {
+		return nil
+	}
+
+	for _, name := range stale {
+		err = repo.Storer.RemoveReference(name)
+		if err != nil {
+			return err
+		}
+		delete(m.refs, name.String())
+		delete(m.prLocks, name.String())
+	}
+
+	err = m.saveRefs()
+	if err != nil {
+		return err
+	}
+
+	err = repo.Prune(git.PruneOptions{Handler: repo.DeleteObject})
+	if err != nil {
+		return err
+	}
+
+	return repo.RepackObjects(&git.RepackConfig{OnlyDeletePacksOlderThan: time.Now()})
+}
+
+// StartGC runs GC once an hour until ctx is canceled
+func (m *Mirror) StartGC(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				err := m.GC()
+				if err != nil {
+					log.Println("Error garbage-collecting mirror:", err)
+				}
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// open opens the mirror, initializing it if it doesn't exist
+// yet. If the existing mirror is corrupted, it's recreated.
+// The caller must hold m.mtx.
+func (m *Mirror) open(url string) error {
+	if m.opened {
+		return nil
+	}
+
+	r, err := git.PlainOpen(m.Dir)
+	if err == nil {
+		_, err = r.Remote("origin")
+	}
+
+	if errors.Is(err, git.ErrRepositoryNotExists) || errors.Is(err, git.ErrRemoteNotFound) {
+		err = os.RemoveAll(m.Dir)
+		if err != nil {
+			return err
+		}
+
+		r, err = git.PlainInit(m.Dir, true)
+		if err != nil {
+			return err
+		}
+
+		_, err = r.CreateRemote(&config.RemoteConfig{
+			Name: "origin",
+			URLs: []string{url},
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	m.opened = true
+	return m.loadRefs()
+}
+
+// prLock returns the lock for the given PR ref.
+// The caller must hold m.mtx.
+func (m *Mirror) prLock(prRef string) *sync.Mutex {
+	if m.prLocks == nil {
+		m.prLocks = map[string]*sync.Mutex{}
+	}
+
+	lock, ok := m.prLocks[prRef]
+	if !ok {
+		lock = &sync.Mutex{}
+		m.prLocks[prRef] = lock
+	}
+	return lock
+}
+
+func (m *Mirror) loadRefs() error {
+	m.refs = map[string]time.Time{}
+
+	data, err := os.ReadFile(filepath.Join(m.Dir, refsFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// If the file is corrupted, all the refs will be
+	// considered stale and cleaned up by the next GC
+	if json.Unmarshal(data, &m.refs) != nil {
+		m.refs = map[string]time.Time{}
+	}
+
+	return nil
+}
+
+// saveRefs atomically writes the refs file.
+// The caller must hold m.mtx.
+func (m *Mirror) saveRefs() error {
+	data, err := json.Marshal(m.refs)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(m.Dir, refsFile)
+	err = os.WriteFile(path+".tmp", data, 0o644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
@@ -0,0 +1,165 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"go.arsenm.dev/lure-repo-bot/internal/types"
+)
+
+// testRepo is a repository that PRs can be fetched from
+type testRepo struct {
+	t    *testing.T
+	dir  string
+	repo *git.Repository
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	dir := t.TempDir()
+	r, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := &testRepo{t, dir, r}
+	tr.commit("foo/lure.sh", "name=foo\n")
+	return tr
+}
+
+// commit writes a file and commits it to the current branch
+func (tr *testRepo) commit(path, content string) plumbing.Hash {
+	err := os.MkdirAll(filepath.Join(tr.dir, filepath.Dir(path)), 0o755)
+	if err != nil {
+		tr.t.Fatal(err)
+	}
+
+	err = os.WriteFile(filepath.Join(tr.dir, path), []byte(content), 0o644)
+	if err != nil {
+		tr.t.Fatal(err)
+	}
+
+	wt, err := tr.repo.Worktree()
+	if err != nil {
+		tr.t.Fatal(err)
+	}
+
+	_, err = wt.Add(path)
+	if err != nil {
+		tr.t.Fatal(err)
+	}
+
+	hash, err := wt.Commit("Update "+path, &git.CommitOptions{
+		Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		tr.t.Fatal(err)
+	}
+	return hash
+}
+
+// pr creates a PR that adds a package, in a commit
+// that isn't reachable from the master branch
+func (tr *testRepo) pr(n int64, pkg string) *types.PullRequest {
+	wt, err := tr.repo.Worktree()
+	if err != nil {
+		tr.t.Fatal(err)
+	}
+
+	branch := plumbing.NewBranchReferenceName(pkg)
+	err = wt.Checkout(&git.CheckoutOptions{Branch: branch, Create: true})
+	if err != nil {
+		tr.t.Fatal(err)
+	}
+
+	hash := tr.commit(pkg+"/lure.sh", "name="+pkg+"\n")
+
+	err = wt.Checkout(&git.CheckoutOptions{Branch: plumbing.Master})
+	if err != nil {
+		tr.t.Fatal(err)
+	}
+
+	// Only the refs/pull ref is fetched, like on GitHub
+	ref := plumbing.NewHashReference(plumbing.ReferenceName(fmt.Sprintf("refs/pull/%d/head", n)), hash)
+	err = tr.repo.Storer.SetReference(ref)
+	if err != nil {
+		tr.t.Fatal(err)
+	}
+
+	pr := &types.PullRequest{Number: n}
+	pr.Base.Ref = "master"
+	pr.Base.Repo.CloneURL = "file://" + tr.dir
+	pr.Head.Sha = hash.String()
+	return pr
+}
+
+func TestMirrorGC(t *testing.T) {
+	tr := newTestRepo(t)
+	m := &Mirror{Dir: t.TempDir(), MaxAge: time.Nanosecond}
+
+	// Each PR is collected right after it's fetched, so
+	// that every GC after the first one has something to do
+	for i, pkg := range []string{"bar", "baz", "qux"} {
+		pr := tr.pr(int64(i+1), pkg)
+
+		fsys, err := m.Fetch(context.Background(), pr, []string{pkg + "/lure.sh"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = fs.Stat(fsys, pkg+"/lure.sh")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = m.GC()
+		if err != nil {
+			t.Fatalf("GC %d: %s", i+1, err)
+		}
+
+		// The PR's commit isn't reachable anymore, so it should be removed
+		r, err := git.PlainOpen(m.Dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = r.CommitObject(plumbing.NewHash(pr.Head.Sha))
+		if err != plumbing.ErrObjectNotFound {
+			t.Errorf("GC %d: the PR's commit wasn't removed: %v", i+1, err)
+		}
+	}
+}
+
+func TestMirrorFetch(t *testing.T) {
+	tr := newTestRepo(t)
+	tr.commit("foo/foo.patch", "patch")
+	tr.commit("README.md", "readme")
+	pr := tr.pr(1, "bar")
+
+	m := &Mirror{Dir: t.TempDir()}
+	fsys, err := m.Fetch(context.Background(), pr, []string{"bar/lure.sh"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The changed package is returned with all of its files,
+	// and only the scripts of the other packages are read
+	for path, want := range map[string]bool{
+		"bar/lure.sh":   true,
+		"foo/lure.sh":   true,
+		"foo/foo.patch": false,
+		"README.md":     false,
+	} {
+		_, err := fs.Stat(fsys, path)
+		if got := err == nil; got != want {
+			t.Errorf("%s: got %v, wanted %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package sandbox
+
+import (
+	"context"
+	"sync"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Command is an external command that a script
+// attempted to run while it was being evaluated
+type Command struct {
+	Args []string
+	// Pos is the position of the command in the script. If the
+	// command couldn't be located exactly, this is the position
+	// of the top-level statement that caused it to run.
+	Pos syntax.Pos
+	// End is the position right after the end of the
+	// command, or an invalid position if it's unknown
+	End syntax.Pos
+	// InSubst is true if the command was run
+	// in a command substitution, such as $(...)
+	InSubst bool
+}
+
+// recorder is an exec handler that records every command
+// instead of running it
+type recorder struct {
+	fl  *syntax.File
+	res *Result
+
+	mtx     sync.Mutex
+	stmt    *syntax.Stmt
+	matched map[*syntax.CallExpr]bool
+}
+
+// setStmt sets the top-level statement that's currently running
+func (r *recorder) setStmt(stmt *syntax.Stmt) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.stmt = stmt
+	r.matched = map[*syntax.CallExpr]bool{}
+}
+
+// access records an attempt to access a file outside of WorkDir
+func (r *recorder) access(path string, write bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	a := Access{Path: path, Write: write}
+	if r.stmt != nil {
+		a.Pos = r.stmt.Pos()
+	}
+	r.res.Accesses = append(r.res.Accesses, a)
+}
+
+func (r *recorder) exec(_ context.Context, args []string) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	cmd := Command{Args: append([]string(nil), args...)}
+	cmd.Pos, cmd.End, cmd.InSubst = r.position(args[0])
+	r.res.Commands = append(r.res.Commands, cmd)
+	return nil
+}
+
+// builtin records a call to a denied builtin. name is the name
+// the call starts with, which may be builtin or command instead
+// of the name of the builtin in args.
+func (r *recorder) builtin(name string, args []string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	call := BuiltinCall{Args: append([]string(nil), args...)}
+	call.Pos, call.End, _ = r.position(name)
+	r.res.Builtins = append(r.res.Builtins, call)
+}
+
+// position returns the range of the call to name that's running.
+// It looks for the call in the current statement first, and then
+// in the rest of the file, since it may be in a function that
+// was called by the current statement. If the call can't be
+// found, it returns the position of the current statement.
+func (r *recorder) position(name string) (pos, end syntax.Pos, inSubst bool) {
+	if r.stmt != nil {
+		pos = r.stmt.Pos()
+	}
+
+	var call *syntax.CallExpr
+	if r.stmt != nil {
+		call, inSubst = r.locate(r.stmt, name)
+	}
+	if call == nil {
+		call, inSubst = r.locate(r.fl, name)
+	}
+	if call == nil {
+		return pos, end, false
+	}
+
+	r.matched[call] = true
+	return call.Pos(), call.End(), inSubst
+}
+
+// locate finds the first call expression in node whose name is
+// name and that hasn't been matched yet. If they've all been
+// matched (for example, in a loop), the first one is returned.
+func (r *recorder) locate(node syntax.Node, name string) (*syntax.CallExpr, bool) {
+	type candidate struct {
+		call    *syntax.CallExpr
+		inSubst bool
+	}
+	var candidates []candidate
+
+	var substs []*syntax.CmdSubst
+	syntax.Walk(node, func(n syntax.Node) bool {
+		switch n := n.(type) {
+		case *syntax.CmdSubst:
+			substs = append(substs, n)
+		case *syntax.CallExpr:
+			if len(n.Args) == 0 || n.Args[0].Lit() != name {
+				return true
+			}
+
+			inSubst := false
+			for _, cs := range substs {
+				if posWithin(n.Pos(), cs.Pos(), cs.End()) {
+					inSubst = true
+					break
+				}
+			}
+
+			candidates = append(candidates, candidate{n, inSubst})
+		}
+		return true
+	})
+
+	if len(candidates) == 0 {
+		return nil, false
+	}
+
+	for _, c := range candidates {
+		if !r.matched[c.call] {
+			return c.call, c.inSubst
+		}
+	}
+
+	return candidates[0].call, candidates[0].inSubst
+}
+
+func posWithin(pos, start, end syntax.Pos) bool {
+	return pos.Offset() >= start.Offset() && pos.Offset() < end.Offset()
+}
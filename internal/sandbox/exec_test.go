@@ -0,0 +1,90 @@
+package sandbox
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	type cmd struct {
+		args    string
+		pos     string
+		inSubst bool
+	}
+
+	tests := []struct {
+		name   string
+		script string
+		want   []cmd
+	}{
+		{
+			name:   "TopLevel",
+			script: "name=x\ngit describe --tags\n",
+			want:   []cmd{{"git describe --tags", "2:1-2:20", false}},
+		},
+		{
+			name:   "Substitution",
+			script: "version=$(git describe)\n",
+			want:   []cmd{{"git describe", "1:11-1:23", true}},
+		},
+		{
+			name:   "Backquotes",
+			script: "version=`git describe`\n",
+			want:   []cmd{{"git describe", "1:10-1:22", true}},
+		},
+		{
+			// Each call is matched to a different call expression
+			name:   "SameCommand",
+			script: "x=\"$(uname -m)-$(uname -r)\"\n",
+			want: []cmd{
+				{"uname -m", "1:6-1:14", true},
+				{"uname -r", "1:18-1:26", true},
+			},
+		},
+		{
+			// Calls in loops are matched to the same call expression
+			name:   "Loop",
+			script: "for i in 1 2 3; do\n\tcurl $i\ndone\n",
+			want: []cmd{
+				{"curl 1", "2:2-2:9", false},
+				{"curl 2", "2:2-2:9", false},
+				{"curl 3", "2:2-2:9", false},
+			},
+		},
+		{
+			name:   "Function",
+			script: "f() {\n\tmake\n}\nx=$(f)\n",
+			want:   []cmd{{"make", "2:2-2:6", false}},
+		},
+		{
+			// The command name isn't a literal, so the
+			// position of the statement is used instead
+			name:   "Dynamic",
+			script: "cmd=git\nx=$($cmd status)\n",
+			want:   []cmd{{"git status", "2:1-0:0", false}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := evaluate(t, DefaultConfig, tt.script)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []cmd
+			for _, c := range res.Commands {
+				got = append(got, cmd{
+					args:    strings.Join(c.Args, " "),
+					pos:     fmt.Sprintf("%d:%d-%d:%d", c.Pos.Line(), c.Pos.Col(), c.End.Line(), c.End.Col()),
+					inSubst: c.InSubst,
+				})
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, wanted %v", got, tt.want)
+			}
+		})
+	}
+}
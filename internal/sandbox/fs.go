@@ -0,0 +1,240 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing/fstest"
+
+	"go.arsenm.dev/lure-repo-bot/internal/shutils"
+	"mvdan.cc/sh/v3/interp"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// WorkDir is the virtual working directory of a script being evaluated.
+// The files in Config.FS are available inside it.
+const WorkDir = "/pkg"
+
+// Access is an attempt by a script to access
+// a file that it isn't allowed to access
+type Access struct {
+	// Path is the absolute path that the script tried to access
+	Path string
+	// Write is true if the script tried to write to the file
+	Write bool
+	// Pos is the position of the top-level
+	// statement that tried to access the file
+	Pos syntax.Pos
+}
+
+// sourceDir is the virtual directory that contains the
+// checked and instrumented copies of files run using source
+const sourceDir = "/dev/lure-sandbox"
+
+// vfs provides read-only access to the files in
+// a filesystem to the interpreter's file handlers
+type vfs struct {
+	fsys fs.FS
+	rec  *recorder
+
+	mtx sync.Mutex
+	// sources contains the prepared copies of files run
+	// using source, with the same paths as in fsys
+	sources fstest.MapFS
+}
+
+func newVFS(fsys fs.FS, rec *recorder) *vfs {
+	if fsys == nil {
+		fsys = fstest.MapFS{}
+	}
+	return &vfs{fsys: fsys, rec: rec, sources: fstest.MapFS{}}
+}
+
+// prepareSource prepares the file at name to be run using source,
+// and returns the path that the interpreter should open instead.
+// The interpreter parses sourced files itself, so the copy has
+// the same checks and instrumentation as the script. If name
+// can't be read or parsed, it's returned unchanged, so that the
+// interpreter reports the error. If the file wasn't prepared
+// before, it's returned as well, so that its functions can be
+// checked when they're called.
+func (v *vfs) prepareSource(ctx context.Context, name string, maxBraceSize int) (string, *syntax.File, error) {
+	abs, rel, ok := v.resolve(ctx, name)
+	if !ok {
+		return name, nil, nil
+	}
+
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	// The files can't change, so each one only has to be prepared once
+	prepared := path.Join(sourceDir, rel)
+	if _, ok := v.sources[rel]; ok {
+		return prepared, nil, nil
+	}
+
+	data, err := fs.ReadFile(v.fsys, rel)
+	if err != nil {
+		return name, nil, nil
+	}
+
+	fl, err := syntax.NewParser().Parse(bytes.NewReader(data), abs)
+	if err != nil {
+		return name, nil, nil
+	}
+
+	if maxBraceSize > 0 && exceedsBraceSize(fl, maxBraceSize) {
+		return "", nil, ErrBraceLimit
+	}
+	if uerr := findUnsupported(fl, true); uerr != nil {
+		return "", nil, uerr
+	}
+	instrument(fl, true)
+
+	buf := &bytes.Buffer{}
+	err = syntax.NewPrinter().Print(buf, fl)
+	if err != nil {
+		return "", nil, err
+	}
+
+	v.sources[rel] = &fstest.MapFile{Data: buf.Bytes()}
+	return prepared, fl, nil
+}
+
+// resolve converts a path given to a handler into a path within the
+// filesystem. If the path is outside of WorkDir, it returns false.
+func (v *vfs) resolve(ctx context.Context, name string) (string, string, bool) {
+	if !path.IsAbs(name) {
+		name = path.Join(interp.HandlerCtx(ctx).Dir, name)
+	}
+	name = path.Clean(name)
+
+	if name == WorkDir {
+		return name, ".", true
+	}
+
+	rel := strings.TrimPrefix(name, WorkDir+"/")
+	if rel == name {
+		return name, "", false
+	}
+	return name, rel, true
+}
+
+func (v *vfs) open(ctx context.Context, name string, flag int, _ os.FileMode) (io.ReadWriteCloser, error) {
+	// Discarding output is common, and harmless
+	if name == os.DevNull {
+		return shutils.NopRWC{}, nil
+	}
+
+	if rel := strings.TrimPrefix(name, sourceDir+"/"); rel != name && flag == os.O_RDONLY {
+		v.mtx.Lock()
+		defer v.mtx.Unlock()
+
+		fl, err := v.sources.Open(rel)
+		if err != nil {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+		return readOnlyFile{fl}, nil
+	}
+
+	abs, rel, ok := v.resolve(ctx, name)
+	write := flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0
+	if !ok || write {
+		// Only the command fails, like it would without permission.
+		// A non-zero exit status doesn't stop the evaluation.
+		v.rec.access(abs, write)
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+	}
+
+	fl, err := v.fsys.Open(rel)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+
+	return readOnlyFile{fl}, nil
+}
+
+func (v *vfs) stat(ctx context.Context, name string, _ bool) (os.FileInfo, error) {
+	abs, rel, ok := v.resolve(ctx, name)
+	if !ok {
+		v.rec.access(abs, false)
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+
+	return fs.Stat(v.fsys, rel)
+}
+
+func (v *vfs) readDir(ctx context.Context, name string) ([]os.FileInfo, error) {
+	abs, rel, ok := v.resolve(ctx, name)
+	if !ok {
+		v.rec.access(abs, false)
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: os.ErrNotExist}
+	}
+
+	entries, err := fs.ReadDir(v.fsys, rel)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, info)
+	}
+	return out, nil
+}
+
+// readOnlyFile adapts an fs.File to the io.ReadWriteCloser
+// expected by the interpreter
+type readOnlyFile struct {
+	fs.File
+}
+
+func (readOnlyFile) Write([]byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+// DirFS returns a filesystem for the files in dir. Unlike os.DirFS,
+// it doesn't follow symbolic links, so they can't be used to give a
+// script access to files outside of dir. Symbolic links are
+// reported as not existing.
+func DirFS(dir string) fs.FS {
+	return dirFS(dir)
+}
+
+type dirFS string
+
+func (d dirFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	// Every element of the path is checked, since a symlink
+	// to a directory would also give access to its files
+	p := string(d)
+	if name != "." {
+		for _, elem := range strings.Split(name, "/") {
+			p = filepath.Join(p, elem)
+
+			info, err := os.Lstat(p)
+			if err != nil {
+				return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+			}
+
+			if info.Mode()&fs.ModeSymlink != 0 {
+				return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+			}
+		}
+	}
+
+	return os.Open(p)
+}
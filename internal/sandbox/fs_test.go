@@ -0,0 +1,253 @@
+package sandbox
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestVFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"common.sh":     {Data: []byte("x=common")},
+		"version":       {Data: []byte("1.0")},
+		"files/a.patch": {Data: []byte("a")},
+	}
+
+	tests := []struct {
+		name   string
+		script string
+		// x is the expected value of $x
+		x        string
+		accesses []Access
+	}{
+		{
+			name:   "Relative",
+			script: "x=$(< version)",
+			x:      "1.0",
+		},
+		{
+			name:   "Absolute",
+			script: "x=$(< /pkg/version)",
+			x:      "1.0",
+		},
+		{
+			name:   "Subdirectory",
+			script: "x=$(< ./files/a.patch)",
+			x:      "a",
+		},
+		{
+			name:   "Source",
+			script: "source ./common.sh",
+			x:      "common",
+		},
+		{
+			name:   "Glob",
+			script: "x=(files/*)",
+			x:      "files/a.patch",
+		},
+		{
+			name:   "Missing",
+			script: "x=$(< missing)",
+		},
+		{
+			name:     "DotDot",
+			script:   "x=$(< ../etc/passwd)",
+			accesses: []Access{{Path: "/etc/passwd"}},
+		},
+		{
+			name:     "DotDotInside",
+			script:   "x=$(< files/../../pkg/../etc/passwd)",
+			accesses: []Access{{Path: "/etc/passwd"}},
+		},
+		{
+			name:     "AbsoluteOutside",
+			script:   "x=$(< /etc/os-release)",
+			accesses: []Access{{Path: "/etc/os-release"}},
+		},
+		{
+			// /pkgs starts with WorkDir, but isn't inside it
+			name:     "Prefix",
+			script:   "x=$(< /pkgs/version)",
+			accesses: []Access{{Path: "/pkgs/version"}},
+		},
+		{
+			name:     "Stat",
+			script:   "[[ -f /etc/passwd ]] && x=1",
+			accesses: []Access{{Path: "/etc/passwd"}},
+		},
+		{
+			name:     "ReadDir",
+			script:   "x=(/etc/*)",
+			x:        "/etc/*",
+			accesses: []Access{{Path: "/etc"}},
+		},
+		{
+			name:     "SourceOutside",
+			script:   "source /etc/profile",
+			accesses: []Access{{Path: "/etc/profile"}},
+		},
+		{
+			name:     "Redirect",
+			script:   "echo hi > out.txt",
+			accesses: []Access{{Path: "/pkg/out.txt", Write: true}},
+		},
+		{
+			name:     "RedirectExisting",
+			script:   "echo hi > version",
+			accesses: []Access{{Path: "/pkg/version", Write: true}},
+		},
+		{
+			name:     "Append",
+			script:   "echo hi >> /tmp/x",
+			accesses: []Access{{Path: "/tmp/x", Write: true}},
+		},
+		{
+			name:     "Truncate",
+			script:   ": > /tmp/foo",
+			accesses: []Access{{Path: "/tmp/foo", Write: true}},
+		},
+		{
+			name:     "RedirectAll",
+			script:   "echo hi &> log",
+			accesses: []Access{{Path: "/pkg/log", Write: true}},
+		},
+		{
+			name:   "DevNull",
+			script: "echo hi > /dev/null",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The access is the last statement, so the script's
+			// exit status is the status of the denied access
+			res, err := evaluate(t, Config{FS: fsys}, tt.script+"\n")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if x := res.Runner.Vars["x"].String(); x != tt.x {
+				t.Errorf("x: got %q, wanted %q", x, tt.x)
+			}
+
+			if len(res.Accesses) != len(tt.accesses) {
+				t.Fatalf("got accesses %v, wanted %v", res.Accesses, tt.accesses)
+			}
+			for i, access := range res.Accesses {
+				if access.Path != tt.accesses[i].Path || access.Write != tt.accesses[i].Write {
+					t.Errorf("got access %v, wanted %v", access, tt.accesses[i])
+				}
+				if access.Pos.Line() != 1 {
+					t.Errorf("got access position %s, wanted line 1", access.Pos)
+				}
+			}
+		})
+	}
+}
+
+func TestDirFS(t *testing.T) {
+	outside := t.TempDir()
+	err := os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	err = os.MkdirAll(filepath.Join(dir, "files"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "version"), []byte("1.0"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "files", "a.patch"), []byte("a"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	links := map[string]string{
+		"file-link":         filepath.Join(outside, "secret"),
+		"dir-link":          outside,
+		"inside-link":       filepath.Join(dir, "version"),
+		"files/nested-link": filepath.Join(outside, "secret"),
+	}
+	for name, target := range links {
+		err = os.Symlink(target, filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		// data is the expected content of the file,
+		// or empty if it's a directory
+		data string
+		err  error
+	}{
+		{name: ".", data: ""},
+		{name: "version", data: "1.0"},
+		{name: "files", data: ""},
+		{name: "files/a.patch", data: "a"},
+		{name: "missing", err: fs.ErrNotExist},
+		{name: "file-link", err: fs.ErrNotExist},
+		{name: "dir-link", err: fs.ErrNotExist},
+		{name: "dir-link/secret", err: fs.ErrNotExist},
+		// Links are denied even if they point inside the directory
+		{name: "inside-link", err: fs.ErrNotExist},
+		{name: "files/nested-link", err: fs.ErrNotExist},
+		{name: "../secret", err: fs.ErrInvalid},
+		{name: "files/../version", err: fs.ErrInvalid},
+		{name: "/etc/passwd", err: fs.ErrInvalid},
+	}
+
+	fsys := DirFS(dir)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fl, err := fsys.Open(tt.name)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, wanted %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			defer fl.Close()
+
+			info, err := fl.Stat()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.IsDir() {
+				if tt.data != "" {
+					t.Errorf("got a directory, wanted a file")
+				}
+				return
+			}
+
+			data, err := fs.ReadFile(fsys, tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.data {
+				t.Errorf("got %q, wanted %q", data, tt.data)
+			}
+		})
+	}
+
+	t.Run("Script", func(t *testing.T) {
+		res, err := evaluate(t, Config{FS: fsys}, "x=$(< version)\ny=$(< file-link)\nz=$(< dir-link/secret)\ntrue\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for name, val := range map[string]string{"x": "1.0", "y": "", "z": ""} {
+			if got := res.Runner.Vars[name].String(); got != val {
+				t.Errorf("%s: got %q, wanted %q", name, got, val)
+			}
+		}
+	})
+}
@@ -0,0 +1,487 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/interp"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// framesPerCall is roughly the number of Go stack frames added
+// by each nested function call in the interpreter. Simple function
+// bodies add about 12, and compound commands add a few more.
+const framesPerCall = 24
+
+// pcsPool contains buffers used by exceedsDepth
+var pcsPool = sync.Pool{}
+
+// limiter enforces the limits in a Config using the
+// interpreter's call handler. Subshells and pipelines
+// run in separate goroutines, so it must be thread-safe.
+type limiter struct {
+	cfg    Config
+	rec    *recorder
+	vfs    *vfs
+	steps  int64
+	stdout *limitWriter
+	stderr *limitWriter
+
+	mtx sync.Mutex
+	// funcs contains the declarations of each function,
+	// which are checked for unsupported features when
+	// they're first called
+	funcs   map[string][]*syntax.FuncDecl
+	checked map[*syntax.FuncDecl]bool
+	// err is the first limit that was exceeded. Errors returned by
+	// the call handler inside command substitutions and subshells
+	// don't stop the script, so they're recorded here as well.
+	err error
+}
+
+func newLimiter(cfg Config, rec *recorder, vfs *vfs) *limiter {
+	var written int64
+	return &limiter{
+		cfg:     cfg,
+		rec:     rec,
+		vfs:     vfs,
+		stdout:  &limitWriter{max: cfg.MaxOutput, written: &written, discard: true},
+		stderr:  &limitWriter{max: cfg.MaxOutput, written: &written},
+		funcs:   map[string][]*syntax.FuncDecl{},
+		checked: map[*syntax.FuncDecl]bool{},
+	}
+}
+
+// addFuncs adds the functions declared in fl to the
+// functions that are checked when they're called
+func (l *limiter) addFuncs(fl *syntax.File) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	syntax.Walk(fl, func(node syntax.Node) bool {
+		if fn, ok := node.(*syntax.FuncDecl); ok {
+			l.funcs[fn.Name.Value] = append(l.funcs[fn.Name.Value], fn)
+		}
+		return true
+	})
+}
+
+// checkFunc checks the bodies of the functions called name, if
+// they haven't been checked yet. The interpreter doesn't say which
+// declaration is being called, so all of them are checked.
+func (l *limiter) checkFunc(name string) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	for _, fn := range l.funcs[name] {
+		if l.checked[fn] {
+			continue
+		}
+		l.checked[fn] = true
+
+		if uerr := findUnsupported(fn.Body, true); uerr != nil {
+			if l.err == nil {
+				l.err = uerr
+			}
+			return uerr
+		}
+	}
+	return nil
+}
+
+func (l *limiter) call(ctx context.Context, args []string) ([]string, error) {
+	if args[0] == checkCmd {
+		if l.cfg.MaxVarSize > 0 && exceedsVarSize(interp.HandlerCtx(ctx).Env.Each, l.cfg.MaxVarSize) {
+			return nil, l.fail(ErrVarLimit)
+		}
+		return statusCmd(args[1:]), nil
+	}
+
+	if l.cfg.MaxSteps > 0 && atomic.AddInt64(&l.steps, 1) > l.cfg.MaxSteps {
+		return nil, l.fail(ErrStepLimit)
+	}
+
+	if l.stdout.exceeded() {
+		return nil, l.fail(ErrOutputLimit)
+	}
+
+	if l.cfg.MaxDepth > 0 && exceedsDepth(l.cfg.MaxDepth) {
+		return nil, l.fail(ErrDepthLimit)
+	}
+
+	if l.cfg.MaxVarSize > 0 && exceedsVarSize(interp.HandlerCtx(ctx).Env.Each, l.cfg.MaxVarSize) {
+		return nil, l.fail(ErrVarLimit)
+	}
+
+	if err := l.checkFunc(args[0]); err != nil {
+		return nil, err
+	}
+
+	// builtin and command run builtins directly,
+	// without running the call handler again
+	i := 0
+	for i < len(args)-1 && (args[i] == "builtin" || args[i] == "command") {
+		i++
+	}
+
+	switch name := args[i]; {
+	case l.cfg.Builtins[name] == Deny:
+		l.rec.builtin(args[0], args[i:])
+		return []string{"false"}, nil
+	case unsupportedCall(args[i:]):
+		return []string{"false"}, nil
+	case (name == "source" || name == ".") && len(args) > i+1:
+		path, fl, err := l.vfs.prepareSource(ctx, args[i+1], l.cfg.MaxBraceSize)
+		if err != nil {
+			return nil, l.fail(err)
+		}
+		if fl != nil {
+			l.addFuncs(fl)
+		}
+
+		out := append([]string(nil), args...)
+		out[i+1] = path
+		return out, nil
+	}
+
+	return args, nil
+}
+
+// fail records err if it's the first limit that was exceeded
+func (l *limiter) fail(err error) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.err == nil {
+		l.err = err
+	}
+	return err
+}
+
+// exceeded returns the first limit that was exceeded, if any
+func (l *limiter) exceeded() error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.err
+}
+
+// exceedsDepth checks whether the current goroutine is nested more
+// than max function calls deep. The interpreter doesn't expose its
+// call depth, but every nested call grows the Go stack, so the size
+// of the stack is used instead. This is much cheaper than resolving
+// the frames, and it's the stack size that actually needs limiting.
+func exceedsDepth(max int) bool {
+	size := max * framesPerCall
+	pcs, ok := pcsPool.Get().([]uintptr)
+	if !ok || len(pcs) != size {
+		pcs = make([]uintptr, size)
+	}
+	defer pcsPool.Put(pcs)
+
+	return runtime.Callers(0, pcs) == size
+}
+
+// checkVars returns ErrVarLimit if any of vars is too big
+func (l *limiter) checkVars(vars map[string]expand.Variable) error {
+	if l.cfg.MaxVarSize <= 0 {
+		return nil
+	}
+
+	each := func(fn func(string, expand.Variable) bool) {
+		for name, v := range vars {
+			if !fn(name, v) {
+				return
+			}
+		}
+	}
+
+	if exceedsVarSize(each, l.cfg.MaxVarSize) {
+		return l.fail(ErrVarLimit)
+	}
+	return nil
+}
+
+// statusCmd returns a command that exits with the status in args,
+// which is the expanded $? of a check command. Only zero and
+// non-zero are distinguished, since true and false are the only
+// builtins without side effects that set the exit status.
+func statusCmd(args []string) []string {
+	if len(args) > 0 && args[0] != "0" {
+		return []string{"false"}
+	}
+	return []string{"true"}
+}
+
+func exceedsVarSize(each func(func(string, expand.Variable) bool), max int) bool {
+	exceeded := false
+	each(func(_ string, v expand.Variable) bool {
+		size := len(v.Str)
+		for _, elem := range v.List {
+			size += len(elem)
+		}
+		for key, elem := range v.Map {
+			size += len(key) + len(elem)
+		}
+		exceeded = size > max
+		return !exceeded
+	})
+	return exceeded
+}
+
+// exceedsBraceSize checks whether the words produced by the brace
+// expansions in node add up to more than max bytes. Each word
+// counts as its length plus one, so that many empty words are
+// counted as well. Parts of words that can't be known before
+// running the script count as the length of their source code.
+func exceedsBraceSize(node syntax.Node, max int) bool {
+	limit := int64(max) + 1
+
+	var total int64
+	syntax.Walk(node, func(node syntax.Node) bool {
+		word, ok := node.(*syntax.Word)
+		if !ok {
+			return total < limit
+		}
+
+		// SplitBraces replaces the word's parts, so it needs a copy
+		split := *word
+		if syntax.SplitBraces(&split) {
+			n, size := partsSize(split.Parts, limit)
+			total = satAdd(total, satAdd(size, n, limit), limit)
+		}
+		return total < limit
+	})
+
+	return total >= limit
+}
+
+// partsSize returns the number of words and the total size of the
+// words that the brace expansions in parts produce, up to limit
+func partsSize(parts []syntax.WordPart, limit int64) (n, size int64) {
+	n = 1
+	for _, part := range parts {
+		pn, psize := partSize(part, limit)
+		// Every word produced by the previous parts is
+		// followed by every word produced by this part
+		size = satAdd(satMul(size, pn, limit), satMul(psize, n, limit), limit)
+		n = satMul(n, pn, limit)
+	}
+	return n, size
+}
+
+func partSize(part syntax.WordPart, limit int64) (n, size int64) {
+	switch part := part.(type) {
+	case *syntax.Lit:
+		return 1, int64(len(part.Value))
+	case *syntax.BraceExp:
+		if part.Sequence {
+			return sequenceSize(part, limit)
+		}
+
+		for _, elem := range part.Elems {
+			en, esize := partsSize(elem.Parts, limit)
+			n, size = satAdd(n, en, limit), satAdd(size, esize, limit)
+		}
+		return n, size
+	default:
+		return 1, int64(part.End().Offset() - part.Pos().Offset())
+	}
+}
+
+// sequenceSize returns the number of words and the total size of
+// the words produced by a sequence like {1..10} or {a..z..2}, the
+// same way as expand.Braces
+func sequenceSize(br *syntax.BraceExp, limit int64) (n, size int64) {
+	from, err1 := strconv.ParseInt(br.Elems[0].Lit(), 10, 64)
+	to, err2 := strconv.ParseInt(br.Elems[1].Lit(), 10, 64)
+	var width int64
+	if err1 != nil || err2 != nil {
+		// Sequences of characters only use the first byte,
+		// which can become up to two bytes in UTF-8
+		from, to = int64(br.Elems[0].Lit()[0]), int64(br.Elems[1].Lit()[0])
+		width = 2
+	} else {
+		width = int64(len(br.Elems[0].Lit()))
+		if w := int64(len(br.Elems[1].Lit())); w > width {
+			width = w
+		}
+	}
+
+	// Steps in the wrong direction are ignored
+	incr := int64(1)
+	if len(br.Elems) > 2 {
+		step, _ := strconv.ParseInt(br.Elems[2].Lit(), 10, 64)
+		if step > 0 && from <= to {
+			incr = step
+		} else if step < 0 && from > to {
+			incr = -step
+		}
+	}
+
+	// The difference can't overflow as an unsigned integer
+	var diff uint64
+	if from <= to {
+		diff = uint64(to) - uint64(from)
+	} else {
+		diff = uint64(from) - uint64(to)
+	}
+
+	steps := diff / uint64(incr)
+	if steps >= uint64(limit) {
+		return limit, limit
+	}
+
+	n = satAdd(int64(steps), 1, limit)
+	return n, satMul(n, width, limit)
+}
+
+// satAdd returns a+b, or limit if it's larger than limit
+func satAdd(a, b, limit int64) int64 {
+	if a >= limit-b {
+		return limit
+	}
+	return a + b
+}
+
+// satMul returns a*b, or limit if it's larger than limit
+func satMul(a, b, limit int64) int64 {
+	if a != 0 && b > limit/a {
+		return limit
+	}
+	return a * b
+}
+
+// checkCmd is the name of the command that instrument adds after
+// assignments. It's handled by the limiter, and never actually runs.
+const checkCmd = "lure-sandbox-check"
+
+// instrument modifies fl so that the call handler runs whenever
+// the limits need to be checked, and returns a function that
+// undoes the changes:
+//
+//   - A no-op command is added to the start of every loop body, so
+//     that loops like `while [[ 1 ]]; do x=$x$x; done`, which don't
+//     run any commands, still count towards the limits.
+//   - A check command is added after every assignment and declaration,
+//     since those don't run the call handler, but they're the only way
+//     variables can grow. The check command keeps the exit status, as
+//     far as statusCmd can.
+//
+// Top-level statements are only changed if top is true, since run
+// checks the variables after each of them, but it can't do that
+// for files run using source.
+func instrument(fl *syntax.File, top bool) (restore func()) {
+	type stmtList struct {
+		stmts *[]*syntax.Stmt
+		orig  []*syntax.Stmt
+		loop  bool
+	}
+	var lists []stmtList
+	if top {
+		lists = append(lists, stmtList{&fl.Stmts, fl.Stmts, false})
+	}
+
+	syntax.Walk(fl, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.WhileClause:
+			// The condition isn't instrumented, since
+			// adding a command would change its result
+			lists = append(lists, stmtList{&node.Do, node.Do, true})
+		case *syntax.ForClause:
+			lists = append(lists, stmtList{&node.Do, node.Do, true})
+		case *syntax.Block:
+			lists = append(lists, stmtList{&node.Stmts, node.Stmts, false})
+		case *syntax.Subshell:
+			lists = append(lists, stmtList{&node.Stmts, node.Stmts, false})
+		case *syntax.IfClause:
+			lists = append(lists, stmtList{&node.Then, node.Then, false})
+		case *syntax.CaseItem:
+			lists = append(lists, stmtList{&node.Stmts, node.Stmts, false})
+		}
+		return true
+	})
+
+	for _, list := range lists {
+		var out []*syntax.Stmt
+		if list.loop {
+			out = append(out, newCall(&syntax.Lit{Value: ":"}))
+		}
+
+		for _, stmt := range list.orig {
+			out = append(out, stmt)
+			if isAssignment(stmt) {
+				out = append(out, newCall(
+					&syntax.Lit{Value: checkCmd},
+					&syntax.ParamExp{Short: true, Param: &syntax.Lit{Value: "?"}},
+				))
+			}
+		}
+
+		*list.stmts = out
+	}
+
+	return func() {
+		for _, list := range lists {
+			*list.stmts = list.orig
+		}
+	}
+}
+
+// isAssignment checks whether stmt only assigns variables,
+// without running a command
+func isAssignment(stmt *syntax.Stmt) bool {
+	switch cmd := stmt.Cmd.(type) {
+	case *syntax.CallExpr:
+		return len(cmd.Args) == 0 && len(cmd.Assigns) > 0
+	case *syntax.DeclClause:
+		return true
+	default:
+		return false
+	}
+}
+
+// newCall returns a statement that calls a command
+// with one word for each of the given parts
+func newCall(parts ...syntax.WordPart) *syntax.Stmt {
+	call := &syntax.CallExpr{}
+	for _, part := range parts {
+		call.Args = append(call.Args, &syntax.Word{Parts: []syntax.WordPart{part}})
+	}
+	return &syntax.Stmt{Cmd: call}
+}
+
+// limitWriter counts the bytes written to it, and stops
+// writing once the shared limit has been reached
+type limitWriter struct {
+	max     int64
+	written *int64
+	discard bool
+
+	mtx sync.Mutex
+	buf bytes.Buffer
+}
+
+func (lw *limitWriter) Write(b []byte) (int, error) {
+	total := atomic.AddInt64(lw.written, int64(len(b)))
+	if lw.discard || (lw.max > 0 && total > lw.max) {
+		return len(b), nil
+	}
+
+	lw.mtx.Lock()
+	defer lw.mtx.Unlock()
+	return lw.buf.Write(b)
+}
+
+func (lw *limitWriter) exceeded() bool {
+	return lw.max > 0 && atomic.LoadInt64(lw.written) > lw.max
+}
+
+func (lw *limitWriter) String() string {
+	lw.mtx.Lock()
+	defer lw.mtx.Unlock()
+	return lw.buf.String()
+}
@@ -0,0 +1,267 @@
+// Package sandbox evaluates untrusted LURE scripts with
+// no access to the host system and strict resource limits.
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"go.arsenm.dev/lure-repo-bot/internal/shutils"
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/interp"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+var (
+	ErrTimeout     = errors.New("script did not finish evaluating in time")
+	ErrStepLimit   = errors.New("script exceeded the maximum number of commands")
+	ErrOutputLimit = errors.New("script exceeded the maximum output size")
+	ErrVarLimit    = errors.New("script exceeded the maximum variable size")
+	ErrDepthLimit  = errors.New("script exceeded the maximum function call depth")
+	ErrBraceLimit  = errors.New("script contains brace expansions that are too large")
+)
+
+// Policy determines what happens when a script runs a builtin
+type Policy uint8
+
+const (
+	// Allow runs the builtin normally
+	Allow Policy = iota
+	// Deny records the call and makes the builtin fail
+	// with a non-zero exit status instead of running it
+	Deny
+)
+
+// Config contains the limits used when evaluating a script.
+// A zero value for any of the limits disables it.
+type Config struct {
+	// Timeout is the maximum amount of time evaluation can take
+	Timeout time.Duration
+	// MaxSteps is the maximum number of simple commands
+	// (including builtins and function calls) that can be run
+	MaxSteps int64
+	// MaxOutput is the maximum number of bytes the
+	// script can write to stdout and stderr combined
+	MaxOutput int64
+	// MaxVarSize is the maximum size of a single variable in bytes.
+	// For arrays and maps, the sizes of all the elements are added up.
+	MaxVarSize int
+	// MaxDepth is the maximum depth of nested function calls
+	MaxDepth int
+	// MaxBraceSize is the maximum total size in bytes of the words
+	// produced by the brace expansions in a file, such as {1..10}.
+	// Brace expansions are expanded all at once, before any of the
+	// other limits can be checked, so every file is checked before
+	// it runs, including files run using source.
+	MaxBraceSize int
+	// Builtins contains the policies for builtins.
+	// Builtins that aren't in the map are allowed.
+	Builtins map[string]Policy
+	// FS contains the files in the script's directory. They're
+	// available read-only inside WorkDir. If nil, WorkDir is empty.
+	FS fs.FS
+}
+
+// DefaultConfig is the configuration used by Evaluate.
+//
+// Besides the builtins that have side effects, cd, pushd and popd
+// are denied because the interpreter checks directory permissions
+// using the host's user IDs, which files from a git tree don't have,
+// and eval is denied because its code is only parsed while it runs,
+// so it can't be checked for large brace expansions or instrumented.
+// source is allowed, since it can only read files in WorkDir, and
+// those are checked and instrumented like the script itself.
+var DefaultConfig = Config{
+	Timeout:      10 * time.Second,
+	MaxSteps:     100_000,
+	MaxOutput:    1 << 20,
+	MaxVarSize:   1 << 20,
+	MaxDepth:     100,
+	MaxBraceSize: 1 << 20,
+	Builtins: map[string]Policy{
+		"exec":  Deny,
+		"trap":  Deny,
+		"read":  Deny,
+		"cd":    Deny,
+		"pushd": Deny,
+		"popd":  Deny,
+		"eval":  Deny,
+	},
+}
+
+// BuiltinCall is a call to a builtin with a Deny policy
+type BuiltinCall struct {
+	Args []string
+	// Pos is the position of the call in the script. If the
+	// call couldn't be located exactly, this is the position
+	// of the top-level statement that caused it to run.
+	Pos syntax.Pos
+	// End is the position right after the end of the
+	// call, or an invalid position if it's unknown
+	End syntax.Pos
+}
+
+// Result contains the state of a script after evaluation
+type Result struct {
+	Runner *interp.Runner
+	File   *syntax.File
+	// Builtins contains the calls to denied builtins, in order
+	Builtins []BuiltinCall
+	// Commands contains the external commands
+	// the script attempted to run, in order
+	Commands []Command
+	// Accesses contains the attempts to write to files
+	// or to access files outside of WorkDir, in order
+	Accesses []Access
+	// Stderr contains the script's standard error output,
+	// up to MaxOutput bytes
+	Stderr string
+	// ExitStatus is the exit status of the script. A non-zero
+	// status isn't an error, since the commands that fail are
+	// often ones the sandbox denied, like read or writes to files.
+	ExitStatus uint8
+	// FS is the Config.FS the script was evaluated with.
+	// It's nil if the script had no access to files.
+	FS fs.FS
+}
+
+// Evaluate evaluates fl using DefaultConfig, with the files
+// in fsys available inside WorkDir. fsys may be nil.
+func Evaluate(ctx context.Context, fl *syntax.File, fsys fs.FS) (*Result, error) {
+	cfg := DefaultConfig
+	cfg.FS = fsys
+	return cfg.Evaluate(ctx, fl)
+}
+
+// Evaluate runs the top-level code in fl in a sandbox. If the script
+// exceeds any of the limits in c, the returned error will be one of
+// the errors defined in this package. If it uses a feature that
+// can't be evaluated, the error is an *UnsupportedError. A script
+// that exits with a non-zero status doesn't return an error.
+//
+// Evaluate temporarily modifies fl while it's running, so the same
+// file must not be evaluated concurrently.
+func (c Config) Evaluate(ctx context.Context, fl *syntax.File) (*Result, error) {
+	res := &Result{File: fl, FS: c.FS}
+	rec := &recorder{fl: fl, res: res}
+	vfs := newVFS(c.FS, rec)
+	lim := newLimiter(c, rec, vfs)
+
+	runner, err := interp.New(
+		interp.Env(expand.ListEnviron()),
+		interp.StdIO(shutils.NopRWC{}, lim.stdout, lim.stderr),
+		interp.CallHandler(lim.call),
+		interp.ExecHandler(rec.exec),
+		interp.ReadDirHandler(vfs.readDir),
+		interp.OpenHandler(vfs.open),
+		interp.StatHandler(vfs.stat),
+	)
+	if err != nil {
+		return nil, err
+	}
+	// The working directory doesn't exist on the host, so it can't
+	// be set using interp.Dir, which checks that it exists.
+	runner.Dir = WorkDir
+	res.Runner = runner
+
+	if c.MaxBraceSize > 0 && exceedsBraceSize(fl, c.MaxBraceSize) {
+		return res, ErrBraceLimit
+	}
+
+	if uerr := findUnsupported(fl, true); uerr != nil {
+		return res, uerr
+	}
+	lim.addFuncs(fl)
+
+	evalCtx := ctx
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		evalCtx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	restore := instrument(fl, false)
+	err = run(evalCtx, runner, rec, lim, fl)
+	restore()
+
+	res.Stderr = lim.stderr.String()
+
+	if ctx.Err() != nil {
+		// The parent context was canceled, so
+		// this isn't the script's fault
+		return nil, ctx.Err()
+	} else if limErr := lim.exceeded(); limErr != nil {
+		// A limit exceeded inside a command substitution only
+		// makes the substitution fail, so the script may have
+		// finished normally
+		return res, limErr
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		return res, ErrTimeout
+	} else if status, ok := interp.IsExitStatus(err); ok {
+		res.ExitStatus = status
+		return res, nil
+	}
+
+	return res, err
+}
+
+// run runs each top-level statement in fl separately, so that
+// the recorder knows which one is running, and so that the
+// variables can be checked after each one.
+func run(ctx context.Context, runner *interp.Runner, rec *recorder, lim *limiter, fl *syntax.File) error {
+	var err error
+	for _, stmt := range fl.Stmts {
+		rec.setStmt(stmt)
+
+		err = runStmt(ctx, runner, stmt)
+		if runner.Exited() && callsExit(stmt) {
+			return err
+		}
+
+		if varErr := lim.checkVars(runner.Vars); varErr != nil {
+			return varErr
+		}
+
+		// Like a shell, only the exit status of
+		// the last statement should be returned
+		if _, ok := interp.IsExitStatus(err); !ok && err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// runStmt runs stmt, recovering from panics in the interpreter.
+// The features it panics on are rejected before they run, so this
+// should never happen, but it shouldn't take down the whole process.
+func runStmt(ctx context.Context, runner *interp.Runner, stmt *syntax.Stmt) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("interpreter panicked: %v", r)
+		}
+	}()
+	return runner.Run(ctx, stmt)
+}
+
+// callsExit checks whether stmt calls exit outside of a function.
+// The interpreter also reports that the shell exited when a
+// redirection like $(< file) fails, which shouldn't stop the
+// evaluation, since files outside of WorkDir are denied.
+func callsExit(stmt *syntax.Stmt) bool {
+	found := false
+	syntax.Walk(stmt, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.FuncDecl:
+			return false
+		case *syntax.CallExpr:
+			if len(node.Args) > 0 && node.Args[0].Lit() == "exit" {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,425 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+func evaluate(t *testing.T, cfg Config, script string) (*Result, error) {
+	t.Helper()
+	fl, err := syntax.NewParser().Parse(strings.NewReader(script), "lure.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg.Evaluate(context.Background(), fl)
+}
+
+func TestLimitInSubstitution(t *testing.T) {
+	cfg := Config{MaxSteps: 100}
+
+	// The substitution fails, but the script continues
+	// and finishes with a zero exit status
+	_, err := evaluate(t, cfg, "x=$(while :; do :; done)\ny=1\n")
+	if err != ErrStepLimit {
+		t.Errorf("got %v, wanted %v", err, ErrStepLimit)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	doubling := "x=a\n" + strings.Repeat("x=$x$x\n", 40)
+
+	tests := []struct {
+		name   string
+		cfg    Config
+		script string
+		err    error
+	}{
+		{
+			name:   "Steps",
+			cfg:    Config{MaxSteps: 100},
+			script: "while :; do :; done\n",
+			err:    ErrStepLimit,
+		},
+		{
+			name:   "StepsInFunction",
+			cfg:    Config{MaxSteps: 100},
+			script: "f() { for i in {1..1000}; do true; done; }\nf\n",
+			err:    ErrStepLimit,
+		},
+		{
+			name:   "Output",
+			cfg:    Config{MaxOutput: 100},
+			script: "while :; do echo aaaaaaaaaa; done\n",
+			err:    ErrOutputLimit,
+		},
+		{
+			name:   "Stderr",
+			cfg:    Config{MaxOutput: 100},
+			script: "while :; do echo aaaaaaaaaa >&2; done\n",
+			err:    ErrOutputLimit,
+		},
+		{
+			name:   "Depth",
+			cfg:    Config{MaxDepth: 10},
+			script: "f() { f; }\nf\n",
+			err:    ErrDepthLimit,
+		},
+		{
+			name:   "DepthAllowed",
+			cfg:    Config{MaxDepth: 10},
+			script: "f() { if [[ $1 -lt 5 ]]; then f $(($1 + 1)); fi; }\nf 0\n",
+		},
+		{
+			// The loop doesn't run any commands, so it
+			// only stops because of the instrumentation
+			name:   "VarSizeInLoop",
+			cfg:    Config{MaxVarSize: 1000},
+			script: "x=a\nwhile [[ 1 ]]; do x=$x$x; done\n",
+			err:    ErrVarLimit,
+		},
+		{
+			name:   "VarSizeTopLevel",
+			cfg:    Config{MaxVarSize: 1000},
+			script: doubling,
+			err:    ErrVarLimit,
+		},
+		{
+			name:   "VarSizeArray",
+			cfg:    Config{MaxVarSize: 1000},
+			script: "x=()\nwhile [[ 1 ]]; do x+=(aaaaaaaaaa); done\n",
+			err:    ErrVarLimit,
+		},
+		{
+			name:   "VarSizeAllowed",
+			cfg:    Config{MaxVarSize: 1000},
+			script: "x=aaaaaaaaaa\nx=$x$x\n",
+		},
+		{
+			name:   "Timeout",
+			cfg:    Config{Timeout: 50 * time.Millisecond},
+			script: "while [[ 1 ]]; do :; done\n",
+			err:    ErrTimeout,
+		},
+		{
+			name:   "BraceRange",
+			cfg:    Config{MaxBraceSize: 1 << 20},
+			script: "x=({1..20000000})\n",
+			err:    ErrBraceLimit,
+		},
+		{
+			name:   "BraceProduct",
+			cfg:    Config{MaxBraceSize: 1 << 20},
+			script: "x=$(echo " + strings.Repeat("{a,b}", 24) + ")\n",
+			err:    ErrBraceLimit,
+		},
+		{
+			// Functions that are never called are still checked
+			name:   "BraceInFunction",
+			cfg:    Config{MaxBraceSize: 1 << 20},
+			script: "f() { echo {1..20000000}; }\n",
+			err:    ErrBraceLimit,
+		},
+		{
+			name:   "BraceAllowed",
+			cfg:    Config{MaxBraceSize: 1 << 20},
+			script: "x=({1..100} {a,b}{c,d})\n",
+		},
+		{
+			name: "BraceInSource",
+			cfg: Config{
+				MaxBraceSize: 1 << 20,
+				FS:           fstest.MapFS{"big.sh": {Data: []byte("x=({1..20000000})\n")}},
+			},
+			script: "source ./big.sh\n",
+			err:    ErrBraceLimit,
+		},
+		{
+			name: "VarSizeInSourcedLoop",
+			cfg: Config{
+				MaxVarSize: 1000,
+				FS:         fstest.MapFS{"loop.sh": {Data: []byte("x=a\nwhile [[ 1 ]]; do x=$x$x; done\n")}},
+			},
+			script: "source ./loop.sh\n",
+			err:    ErrVarLimit,
+		},
+		{
+			name: "VarSizeInSourcedTopLevel",
+			cfg: Config{
+				MaxVarSize: 1000,
+				FS:         fstest.MapFS{"double.sh": {Data: []byte(doubling)}},
+			},
+			script: "f() { source ./double.sh; }\nf\n",
+			err:    ErrVarLimit,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := evaluate(t, tt.cfg, tt.script)
+			if err != tt.err {
+				t.Errorf("got %v, wanted %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		// feature is the expected feature, or
+		// empty if the script should evaluate
+		feature string
+		line    uint
+	}{
+		{"ReadWrite", "true <> version", "the `<>` redirection", 1},
+		{"DupIn", "name=x\nread y <&3", "the `<&` redirection", 2},
+		{"Clobber", "echo > x; echo >| x", "the `>|` redirection", 1},
+		{"Pipeline", "(true <> x) | true", "the `<>` redirection", 1},
+		{"Background", "true <> x &", "the `<>` redirection", 1},
+		{"ProcSubst", "x=$(cat <(true))", "process substitution", 1},
+		{"ExtGlob", "case a in @(a|b)) x=1 ;; esac", "extended globs", 1},
+		{"Coproc", "coproc true", "`coproc`", 1},
+		{"ParamOp", "x=${y@P}", "the `@P` expansion", 1},
+		{"Test", "[[ -N x ]]", "the `-N` test", 1},
+		{"NameRef", "x=1\ndeclare -n r=x\nr+=(a)", "name references", 2},
+		{"CalledFunction", "f() {\n\tdiff <(true) <(true)\n}\nf", "process substitution", 2},
+		{"CalledNestedFunction", "f() { g() { true <> x; }; }\nf\ng", "the `<>` redirection", 1},
+		{"SourcedFile", "source ./unsupported.sh", "the `<>` redirection", 1},
+		{"SourcedFunction", "source ./func.sh\nf", "process substitution", 1},
+		{"Function", "f() { diff <(true) <(true); }\npackage() { true <> x; }", "", 0},
+		{"ParamQuote", "y=a\nx=${y@Q}", "", 0},
+		{"Wait", "wait 1", "", 0},
+		{"Shopt", "shopt -q extglob", "", 0},
+		{"Umask", "umask", "", 0},
+		{"TestBuiltin", "test -N x", "", 0},
+	}
+
+	fsys := fstest.MapFS{
+		"unsupported.sh": {Data: []byte("true <> x\n")},
+		"func.sh":        {Data: []byte("f() { diff <(true) <(true); }\n")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			fl, err := syntax.NewParser().Parse(strings.NewReader(tt.script+"\ntrue\n"), "lure.sh")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = Config{FS: fsys}.Evaluate(ctx, fl)
+			if tt.feature == "" {
+				if err != nil {
+					t.Errorf("got %v, wanted no error", err)
+				}
+				return
+			}
+
+			var uerr *UnsupportedError
+			if !errors.As(err, &uerr) {
+				t.Fatalf("got %v, wanted an *UnsupportedError", err)
+			}
+
+			if uerr.Feature != tt.feature {
+				t.Errorf("got feature %q, wanted %q", uerr.Feature, tt.feature)
+			}
+
+			if uerr.Pos.Line() != tt.line {
+				t.Errorf("got position %s, wanted line %d", uerr.Pos, tt.line)
+			}
+		})
+	}
+}
+
+func TestExceedsBraceSize(t *testing.T) {
+	tests := []struct {
+		word string
+		// size is the number of bytes the word expands
+		// to, including one separator for each word
+		size int
+	}{
+		{"abc", 0},
+		{"{a,b}", 4},
+		{"x{a,b}", 6},
+		{"{a,b}{c,d}", 12},
+		{"{,}{,}", 4},
+		{"{a,{b,c}}", 6},
+		{"{1..10}", 30},
+		{"{10..1}", 30},
+		{"{1..10..2}", 15},
+		// Steps in the wrong direction are ignored
+		{"{1..10..-2}", 30},
+		{"{a..e}", 15},
+		{"{1..3}{a,b}", 18},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.word, func(t *testing.T) {
+			fl, err := syntax.NewParser().Parse(strings.NewReader("echo "+tt.word+"\n"), "lure.sh")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.size > 0 && !exceedsBraceSize(fl, tt.size-1) {
+				t.Errorf("size %d doesn't exceed %d", tt.size, tt.size-1)
+			}
+			if exceedsBraceSize(fl, tt.size) {
+				t.Errorf("size %d exceeds %d", tt.size, tt.size)
+			}
+		})
+	}
+}
+
+func TestDeniedBuiltins(t *testing.T) {
+	tests := []struct {
+		script string
+		args   []string
+		line   uint
+		col    uint
+	}{
+		{"trap 'echo hi' EXIT\n", []string{"trap", "echo hi", "EXIT"}, 1, 1},
+		{"name=x\n  cd /tmp\n", []string{"cd", "/tmp"}, 2, 3},
+		{"eval 'x=1'\n", []string{"eval", "x=1"}, 1, 1},
+		{"builtin eval 'x=1'\n", []string{"eval", "x=1"}, 1, 1},
+		{"command cd ..\n", []string{"cd", ".."}, 1, 1},
+		{"x=$(read y)\n", []string{"read", "y"}, 1, 5},
+		{"f() {\n\texec true\n}\nf\n", []string{"exec", "true"}, 2, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.args[0], func(t *testing.T) {
+			res, err := evaluate(t, DefaultConfig, tt.script)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, ok := res.Runner.Vars["x"]; ok && tt.args[0] == "eval" {
+				t.Error("eval ran")
+			}
+
+			if len(res.Builtins) != 1 {
+				t.Fatalf("got %d builtin calls, wanted 1", len(res.Builtins))
+			}
+			call := res.Builtins[0]
+
+			if strings.Join(call.Args, " ") != strings.Join(tt.args, " ") {
+				t.Errorf("got args %q, wanted %q", call.Args, tt.args)
+			}
+
+			if call.Pos.Line() != tt.line || call.Pos.Col() != tt.col {
+				t.Errorf("got position %s, wanted %d:%d", call.Pos, tt.line, tt.col)
+			}
+
+			if !call.End.IsValid() {
+				t.Error("got an invalid end position")
+			}
+		})
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		status   uint8
+		builtins int
+		accesses int
+	}{
+		{"success", "x=1\n", 0, 0, 0},
+		{"failed test", "x=1\n[ -n \"\" ] && y=1\n", 1, 0, 0},
+		{"failed earlier", "false\nx=1\n", 0, 0, 0},
+		{"denied builtin", "x=1\nread -r y\n", 1, 1, 0},
+		{"blocked write", "x=1\n: > /tmp/foo\n", 1, 0, 1},
+		{"blocked read", "x=1\n: < /etc/os-release\n", 1, 0, 1},
+		{"exit", "x=1\nexit 3\ny=2\n", 3, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := evaluate(t, DefaultConfig, tt.script)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.ExitStatus != tt.status {
+				t.Errorf("got exit status %d, wanted %d", res.ExitStatus, tt.status)
+			}
+
+			// The results from before the failure are kept
+			if got := res.Runner.Vars["x"].String(); got != "1" {
+				t.Errorf("got x=%q, wanted x=1", got)
+			}
+			if _, ok := res.Runner.Vars["y"]; ok {
+				t.Error("got y, wanted it to be unset")
+			}
+
+			if len(res.Builtins) != tt.builtins {
+				t.Errorf("got %d builtin calls, wanted %d", len(res.Builtins), tt.builtins)
+			}
+			if len(res.Accesses) != tt.accesses {
+				t.Errorf("got %d accesses, wanted %d", len(res.Accesses), tt.accesses)
+			}
+		})
+	}
+}
+
+func TestSource(t *testing.T) {
+	cfg := Config{
+		FS: fstest.MapFS{
+			"common.sh":     {Data: []byte("x=1\nf() { y=2; }\n")},
+			"lib/nested.sh": {Data: []byte("source ./common.sh\nz=3\n")},
+		},
+	}
+
+	res, err := evaluate(t, cfg, "source ./lib/nested.sh\nf\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, val := range map[string]string{"x": "1", "y": "2", "z": "3"} {
+		if got := res.Runner.Vars[name].String(); got != val {
+			t.Errorf("%s: got %q, wanted %q", name, got, val)
+		}
+	}
+
+	if len(res.Accesses) != 0 {
+		t.Errorf("got denied accesses %v, wanted none", res.Accesses)
+	}
+}
+
+func TestInstrumentRestore(t *testing.T) {
+	script := "x=1\nwhile [[ 1 ]]; do\n\tx=$x$x\n\tbreak\ndone\nif true; then\n\ty=2\nfi\n"
+	fl, err := syntax.NewParser().Parse(strings.NewReader(script), "lure.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var before bytes.Buffer
+	syntax.NewPrinter().Print(&before, fl)
+
+	restore := instrument(fl, true)
+
+	var during bytes.Buffer
+	syntax.NewPrinter().Print(&during, fl)
+	if strings.Count(during.String(), checkCmd) != 3 {
+		t.Errorf("got %d check commands, wanted 3:\n%s", strings.Count(during.String(), checkCmd), during.String())
+	}
+
+	restore()
+
+	var after bytes.Buffer
+	syntax.NewPrinter().Print(&after, fl)
+	if before.String() != after.String() {
+		t.Errorf("the file wasn't restored:\n%s", after.String())
+	}
+}
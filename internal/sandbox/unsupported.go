@@ -0,0 +1,117 @@
+package sandbox
+
+import (
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// UnsupportedError is returned when a script uses a shell feature
+// that the interpreter can't evaluate safely. The interpreter panics
+// on some features that it doesn't implement, which can't always be
+// recovered, since pipelines and background jobs run in separate
+// goroutines, and it implements process substitution using named
+// pipes on the host, which can block forever.
+type UnsupportedError struct {
+	// Feature describes the feature, such as "process substitution"
+	Feature string
+	// Pos is the position where the feature is used
+	Pos syntax.Pos
+}
+
+func (e *UnsupportedError) Error() string {
+	return "script uses " + e.Feature + ", which can't be evaluated"
+}
+
+// findUnsupported returns an error for the first unsupported
+// feature in node. If skipFuncs is true, function bodies aren't
+// checked, since they're checked when they're called instead.
+func findUnsupported(node syntax.Node, skipFuncs bool) *UnsupportedError {
+	var uerr *UnsupportedError
+	syntax.Walk(node, func(node syntax.Node) bool {
+		if uerr != nil {
+			return false
+		}
+
+		switch node := node.(type) {
+		case *syntax.FuncDecl:
+			return !skipFuncs
+		case *syntax.Redirect:
+			switch node.Op {
+			case syntax.RdrInOut, syntax.DplIn, syntax.ClbOut:
+				uerr = &UnsupportedError{"the `" + node.Op.String() + "` redirection", node.OpPos}
+			}
+		case *syntax.ProcSubst:
+			uerr = &UnsupportedError{"process substitution", node.Pos()}
+		case *syntax.ExtGlob:
+			uerr = &UnsupportedError{"extended globs", node.Pos()}
+		case *syntax.CoprocClause:
+			uerr = &UnsupportedError{"`coproc`", node.Pos()}
+		case *syntax.UnaryTest:
+			switch node.Op {
+			case syntax.TsGrpOwn, syntax.TsUsrOwn, syntax.TsModif:
+				uerr = &UnsupportedError{"the `" + node.Op.String() + "` test", node.OpPos}
+			}
+		case *syntax.ParamExp:
+			// Only @Q and @E are implemented
+			if node.Exp != nil && node.Exp.Op == syntax.OtherParamOps {
+				if op := node.Exp.Word.Lit(); op != "Q" && op != "E" {
+					uerr = &UnsupportedError{"the `@" + op + "` expansion", node.Pos()}
+				}
+			}
+		case *syntax.DeclClause:
+			// Appending to name references panics
+			if isNameRef(node) {
+				uerr = &UnsupportedError{"name references", node.Pos()}
+			}
+		}
+		return uerr == nil
+	})
+	return uerr
+}
+
+func isNameRef(decl *syntax.DeclClause) bool {
+	switch decl.Variant.Value {
+	case "nameref":
+		return true
+	case "declare", "local", "typeset":
+	default:
+		return false
+	}
+
+	for _, arg := range decl.Args {
+		if !arg.Naked || arg.Value == nil {
+			continue
+		}
+		if flags := arg.Value.Lit(); strings.HasPrefix(flags, "-") && strings.Contains(flags, "n") {
+			return true
+		}
+	}
+	return false
+}
+
+// unsupportedCall checks whether args uses a builtin in a way that
+// the interpreter doesn't implement. These depend on the arguments,
+// so they can only be checked while the script is running.
+func unsupportedCall(args []string) bool {
+	switch args[0] {
+	case "umask":
+		return true
+	case "wait":
+		return len(args) > 1
+	case "shopt":
+		for _, arg := range args[1:] {
+			if strings.HasPrefix(arg, "-") && strings.ContainsAny(arg, "pq") {
+				return true
+			}
+		}
+	case "test", "[":
+		for _, arg := range args[1:] {
+			switch arg {
+			case "-G", "-O", "-N":
+				return true
+			}
+		}
+	}
+	return false
+}
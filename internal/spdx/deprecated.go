@@ -0,0 +1,61 @@
+package spdx
+
+import "golang.org/x/exp/slices"
+
+// gnuLicenses are the deprecated GNU license IDs, which
+// were replaced by -only and -or-later variants
+var gnuLicenses = []string{
+	"GPL-1.0", "GPL-2.0", "GPL-3.0",
+	"LGPL-2.0", "LGPL-2.1", "LGPL-3.0",
+	"AGPL-1.0", "AGPL-3.0",
+	"GFDL-1.1", "GFDL-1.2", "GFDL-1.3",
+}
+
+// replacements contains the replacements for
+// the other deprecated license IDs
+var replacements = map[string]License{
+	"GPL-2.0-with-autoconf-exception":  {ID: "GPL-2.0-only", Exception: "Autoconf-exception-2.0"},
+	"GPL-2.0-with-bison-exception":     {ID: "GPL-2.0-only", Exception: "Bison-exception-2.2"},
+	"GPL-2.0-with-classpath-exception": {ID: "GPL-2.0-only", Exception: "Classpath-exception-2.0"},
+	"GPL-2.0-with-font-exception":      {ID: "GPL-2.0-only", Exception: "Font-exception-2.0"},
+	"GPL-2.0-with-GCC-exception":       {ID: "GPL-2.0-only", Exception: "GCC-exception-2.0"},
+	"GPL-3.0-with-autoconf-exception":  {ID: "GPL-3.0-only", Exception: "Autoconf-exception-3.0"},
+	"GPL-3.0-with-GCC-exception":       {ID: "GPL-3.0-only", Exception: "GCC-exception-3.1"},
+	"eCos-2.0":                         {ID: "GPL-2.0-or-later", Exception: "eCos-exception-2.0"},
+	"wxWindows":                        {ID: "GPL-2.0-or-later", Exception: "WxWindows-exception-3.1"},
+	"BSD-2-Clause-FreeBSD":             {ID: "BSD-2-Clause"},
+	"BSD-2-Clause-NetBSD":              {ID: "BSD-2-Clause"},
+	"StandardML-NJ":                    {ID: "SMLNJ"},
+	"Nunit":                            {ID: "zlib-acknowledgement"},
+	"bzip2-1.0.5":                      {ID: "bzip2-1.0.6"},
+}
+
+// IsDeprecated checks whether l uses a deprecated license ID.
+// A + after a GNU license is deprecated too, since those
+// licenses have -or-later variants.
+func IsDeprecated(l *License) bool {
+	if l.OrLater && slices.Contains(gnuLicenses, l.ID) {
+		return true
+	}
+
+	info := Licenses.License(l.ID)
+	return info != nil && info.Deprecated
+}
+
+// Replacement returns the recommended replacement for a deprecated
+// license, or nil if there isn't one. The exception of l isn't
+// included, so the result can replace l's ID and + in place.
+func Replacement(l *License) *License {
+	if slices.Contains(gnuLicenses, l.ID) {
+		if l.OrLater {
+			return &License{ID: l.ID + "-or-later"}
+		}
+		return &License{ID: l.ID + "-only"}
+	}
+
+	repl, ok := replacements[l.ID]
+	if !ok || l.OrLater || (l.Exception != "" && repl.Exception != "") {
+		return nil
+	}
+	return &repl
+}
@@ -0,0 +1,60 @@
+package spdx
+
+import "sync"
+
+// ExceptionsURL is the URL of the SPDX license exception list
+const ExceptionsURL = "https://spdx.org/licenses/exceptions.json"
+
+// ExceptionList is a list of license exceptions,
+// which can be added to licenses using WITH
+type ExceptionList struct {
+	Version    string           `json:"licenseListVersion"`
+	Exceptions []*ExceptionInfo `json:"exceptions"`
+}
+
+type ExceptionInfo struct {
+	ID         string `json:"licenseExceptionId"`
+	Name       string `json:"name"`
+	Deprecated bool   `json:"isDeprecatedLicenseId"`
+}
+
+// Exception looks up the exception with the given ID.
+// If it's not found, nil is returned.
+func (el *ExceptionList) Exception(id string) *ExceptionInfo {
+	for _, e := range el.Exceptions {
+		if e != nil && e.ID == id {
+			return e
+		}
+	}
+	return nil
+}
+
+// syncExceptionList is like syncLicenseList, but for exceptions
+type syncExceptionList struct {
+	*ExceptionList
+	sync.Mutex
+}
+
+var Exceptions = &syncExceptionList{
+	ExceptionList: &ExceptionList{},
+}
+
+func (sel *syncExceptionList) Exception(id string) *ExceptionInfo {
+	sel.Lock()
+	e := sel.ExceptionList.Exception(id)
+	sel.Unlock()
+	return e
+}
+
+// FindSimilarException finds the most similar
+// exception ID to the one provided
+func FindSimilarException(s string) string {
+	Exceptions.Lock()
+	defer Exceptions.Unlock()
+
+	ids := make([]string, len(Exceptions.Exceptions))
+	for i, e := range Exceptions.Exceptions {
+		ids[i] = e.ID
+	}
+	return findSimilar(s, ids)
+}
@@ -0,0 +1,304 @@
+package spdx
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Expr is a parsed SPDX license expression. It's
+// either a *License or a *BinaryExpr.
+type Expr interface {
+	String() string
+}
+
+// License is a single license in an expression,
+// with an optional exception
+type License struct {
+	// ID is the license identifier. For license references,
+	// it's the full reference, such as LicenseRef-foo
+	// or DocumentRef-bar:LicenseRef-foo.
+	ID string
+	// OrLater is true if the ID was followed by +
+	OrLater bool
+	// Exception is the exception identifier after WITH,
+	// or an empty string if there isn't one
+	Exception string
+	// Offset is the byte offset of the ID in the expression,
+	// and End is the offset right after it, including the +
+	Offset int
+	End    int
+}
+
+// IsRef reports whether the license is a LicenseRef,
+// which refers to a license that's not in the SPDX list
+func (l *License) IsRef() bool {
+	_, ref, _ := strings.Cut(l.ID, ":")
+	if ref == "" {
+		ref = l.ID
+	}
+	return strings.HasPrefix(ref, "LicenseRef-")
+}
+
+func (l *License) String() string {
+	out := l.ID
+	if l.OrLater {
+		out += "+"
+	}
+	if l.Exception != "" {
+		out += " WITH " + l.Exception
+	}
+	return out
+}
+
+// BinaryExpr is an AND or OR expression
+type BinaryExpr struct {
+	// Op is either AND or OR
+	Op string
+	X  Expr
+	Y  Expr
+}
+
+func (b *BinaryExpr) String() string {
+	return "(" + b.X.String() + " " + b.Op + " " + b.Y.String() + ")"
+}
+
+// ExprLicenses returns all the licenses in expr, from left to right
+func ExprLicenses(expr Expr) []*License {
+	switch expr := expr.(type) {
+	case *License:
+		return []*License{expr}
+	case *BinaryExpr:
+		return append(ExprLicenses(expr.X), ExprLicenses(expr.Y)...)
+	default:
+		return nil
+	}
+}
+
+// ExprError is returned when an expression can't be parsed
+type ExprError struct {
+	// Offset is the byte offset of the error in the expression
+	Offset int
+	Msg    string
+}
+
+func (e *ExprError) Error() string {
+	return fmt.Sprintf("offset %d: %s", e.Offset, e.Msg)
+}
+
+// ParseExpr parses an SPDX license expression, such as
+// "MIT OR Apache-2.0" or "GPL-2.0-only WITH Classpath-exception-2.0".
+// WITH binds more tightly than AND, which binds more tightly than OR.
+// The identifiers aren't checked against the license list.
+func ParseExpr(s string) (Expr, error) {
+	p := &exprParser{tokens: tokenize(s), end: len(s)}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok, ok := p.peek(); ok {
+		return nil, &ExprError{tok.offset, "unexpected " + tok.String()}
+	}
+	return expr, nil
+}
+
+type token struct {
+	value  string
+	offset int
+}
+
+func (t token) String() string {
+	return "'" + t.value + "'"
+}
+
+// op returns the operator that t represents, if any.
+// Operators are matched case-insensitively.
+func (t token) op() string {
+	switch upper := strings.ToUpper(t.value); upper {
+	case "AND", "OR", "WITH":
+		return upper
+	default:
+		return ""
+	}
+}
+
+// tokenize splits an expression into parentheses and words
+func tokenize(s string) []token {
+	var out []token
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')':
+			out = append(out, token{s[i : i+1], i})
+			i++
+		default:
+			start := i
+			for i < len(s) && !strings.ContainsRune(" \t\n()", rune(s[i])) {
+				i++
+			}
+			out = append(out, token{s[start:i], start})
+		}
+	}
+	return out
+}
+
+type exprParser struct {
+	tokens []token
+	pos    int
+	// end is the length of the expression,
+	// used as the offset of errors at the end
+	end int
+}
+
+func (p *exprParser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *exprParser) next() (token, error) {
+	tok, ok := p.peek()
+	if !ok {
+		return token{}, &ExprError{p.end, "unexpected end of expression"}
+	}
+	p.pos++
+	return tok, nil
+}
+
+func (p *exprParser) parseOr() (Expr, error) {
+	return p.parseBinary("OR", p.parseAnd)
+}
+
+func (p *exprParser) parseAnd() (Expr, error) {
+	return p.parseBinary("AND", p.parseWith)
+}
+
+// parseBinary parses a left-associative chain of
+// operands separated by op
+func (p *exprParser) parseBinary(op string, operand func() (Expr, error)) (Expr, error) {
+	x, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.op() != op {
+			return x, nil
+		}
+		p.pos++
+
+		y, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		x = &BinaryExpr{Op: op, X: x, Y: y}
+	}
+}
+
+func (p *exprParser) parseWith() (Expr, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	tok, ok := p.peek()
+	if !ok || tok.op() != "WITH" {
+		return x, nil
+	}
+	p.pos++
+
+	license, ok := x.(*License)
+	if !ok {
+		return nil, &ExprError{tok.offset, "WITH must follow a single license"}
+	}
+
+	exc, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if !isIDString(exc.value) {
+		return nil, &ExprError{exc.offset, "invalid exception identifier " + exc.String()}
+	}
+	license.Exception = exc.value
+
+	return license, nil
+}
+
+func (p *exprParser) parsePrimary() (Expr, error) {
+	tok, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok.value == "(" {
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		closing, err := p.next()
+		if err != nil {
+			return nil, &ExprError{p.end, "missing ')'"}
+		} else if closing.value != ")" {
+			return nil, &ExprError{closing.offset, "expected ')', got " + closing.String()}
+		}
+		return expr, nil
+	}
+
+	if tok.value == ")" || tok.op() != "" {
+		return nil, &ExprError{tok.offset, "expected a license identifier, got " + tok.String()}
+	}
+
+	return parseLicense(tok)
+}
+
+// parseLicense parses a license identifier,
+// with an optional + or DocumentRef- prefix
+func parseLicense(tok token) (*License, error) {
+	id := strings.TrimSuffix(tok.value, "+")
+	orLater := id != tok.value
+
+	doc, ref, isDoc := strings.Cut(id, ":")
+	if isDoc {
+		if !strings.HasPrefix(doc, "DocumentRef-") || !isIDString(strings.TrimPrefix(doc, "DocumentRef-")) {
+			return nil, &ExprError{tok.offset, "invalid document reference " + tok.String()}
+		}
+		if !strings.HasPrefix(ref, "LicenseRef-") {
+			return nil, &ExprError{tok.offset, "a document reference must be followed by a LicenseRef"}
+		}
+	} else {
+		ref = id
+	}
+
+	if strings.HasPrefix(ref, "LicenseRef-") {
+		if orLater || !isIDString(strings.TrimPrefix(ref, "LicenseRef-")) {
+			return nil, &ExprError{tok.offset, "invalid license reference " + tok.String()}
+		}
+	} else if !isIDString(id) {
+		return nil, &ExprError{tok.offset, "invalid license identifier " + tok.String()}
+	}
+
+	return &License{
+		ID:      id,
+		OrLater: orLater,
+		Offset:  tok.offset,
+		End:     tok.offset + len(tok.value),
+	}, nil
+}
+
+// isIDString checks whether s only contains the
+// characters allowed in SPDX identifiers
+func isIDString(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') && c != '-' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,124 @@
+package spdx
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseExpr(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"MIT", "MIT"},
+		{"  MIT\t", "MIT"},
+		{"MIT AND Apache-2.0", "(MIT AND Apache-2.0)"},
+		{"MIT OR Apache-2.0", "(MIT OR Apache-2.0)"},
+		{"MIT or Apache-2.0", "(MIT OR Apache-2.0)"},
+		{"MIT OR Apache-2.0 OR BSD-3-Clause", "((MIT OR Apache-2.0) OR BSD-3-Clause)"},
+
+		// AND binds more tightly than OR
+		{"MIT OR Apache-2.0 AND BSD-3-Clause", "(MIT OR (Apache-2.0 AND BSD-3-Clause))"},
+		{"MIT AND Apache-2.0 OR BSD-3-Clause", "((MIT AND Apache-2.0) OR BSD-3-Clause)"},
+
+		// Parentheses
+		{"(MIT)", "MIT"},
+		{"(MIT OR Apache-2.0) AND BSD-3-Clause", "((MIT OR Apache-2.0) AND BSD-3-Clause)"},
+		{"MIT AND (Apache-2.0 OR BSD-3-Clause)", "(MIT AND (Apache-2.0 OR BSD-3-Clause))"},
+		{"((MIT))", "MIT"},
+		{"(MIT)AND(Zlib)", "(MIT AND Zlib)"},
+
+		// WITH binds more tightly than AND
+		{"GPL-2.0-only WITH Classpath-exception-2.0", "GPL-2.0-only WITH Classpath-exception-2.0"},
+		{"MIT AND GPL-2.0-only with Classpath-exception-2.0", "(MIT AND GPL-2.0-only WITH Classpath-exception-2.0)"},
+		{"GPL-2.0+ WITH Bison-exception-2.2", "GPL-2.0+ WITH Bison-exception-2.2"},
+
+		// +
+		{"GPL-2.0+", "GPL-2.0+"},
+		{"LGPL-2.1+ OR MIT", "(LGPL-2.1+ OR MIT)"},
+
+		// References
+		{"LicenseRef-foo", "LicenseRef-foo"},
+		{"LicenseRef-foo.bar-1 OR MIT", "(LicenseRef-foo.bar-1 OR MIT)"},
+		{"DocumentRef-spdx:LicenseRef-foo", "DocumentRef-spdx:LicenseRef-foo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			expr, err := ParseExpr(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := expr.String(); got != tt.want {
+				t.Errorf("got %q, wanted %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExprError(t *testing.T) {
+	tests := []struct {
+		in     string
+		offset int
+		msg    string
+	}{
+		{"", 0, "unexpected end of expression"},
+		{"MIT AND", 7, "unexpected end of expression"},
+		{"MIT OR ", 7, "unexpected end of expression"},
+		{"AND MIT", 0, "expected a license identifier, got 'AND'"},
+		{"MIT AND OR Zlib", 8, "expected a license identifier, got 'OR'"},
+		{"MIT Zlib", 4, "unexpected 'Zlib'"},
+		{"(MIT", 4, "missing ')'"},
+		{"((MIT OR Zlib)", 14, "missing ')'"},
+		{"MIT)", 3, "unexpected ')'"},
+		{"(MIT))", 5, "unexpected ')'"},
+		{"()", 1, "expected a license identifier, got ')'"},
+		{"(MIT Zlib)", 5, "expected ')', got 'Zlib'"},
+		{"MIT WITH", 8, "unexpected end of expression"},
+		{"(MIT OR Zlib) WITH Classpath-exception-2.0", 14, "WITH must follow a single license"},
+		{"MIT WITH foo_bar", 9, "invalid exception identifier 'foo_bar'"},
+		{"MIT/X11", 0, "invalid license identifier 'MIT/X11'"},
+		{"GPL-2.0++", 0, "invalid license identifier 'GPL-2.0++'"},
+		{"LicenseRef-", 0, "invalid license reference 'LicenseRef-'"},
+		{"LicenseRef-foo+", 0, "invalid license reference 'LicenseRef-foo+'"},
+		{"DocumentRef-spdx:MIT", 0, "a document reference must be followed by a LicenseRef"},
+		{"Document-spdx:LicenseRef-foo", 0, "invalid document reference 'Document-spdx:LicenseRef-foo'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			_, err := ParseExpr(tt.in)
+
+			var exprErr *ExprError
+			if !errors.As(err, &exprErr) {
+				t.Fatalf("got %v, wanted an ExprError", err)
+			}
+			if exprErr.Offset != tt.offset || exprErr.Msg != tt.msg {
+				t.Errorf("got (%d, %q), wanted (%d, %q)", exprErr.Offset, exprErr.Msg, tt.offset, tt.msg)
+			}
+		})
+	}
+}
+
+func TestExprLicenses(t *testing.T) {
+	expr, err := ParseExpr("(MIT OR GPL-2.0+) AND Apache-2.0 WITH LLVM-exception")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []License{
+		{ID: "MIT", Offset: 1, End: 4},
+		{ID: "GPL-2.0", OrLater: true, Offset: 8, End: 16},
+		{ID: "Apache-2.0", Exception: "LLVM-exception", Offset: 22, End: 32},
+	}
+
+	got := ExprLicenses(expr)
+	if len(got) != len(want) {
+		t.Fatalf("got %d licenses, wanted %d", len(got), len(want))
+	}
+	for i := range want {
+		if *got[i] != want[i] {
+			t.Errorf("license %d: got %+v, wanted %+v", i, *got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,48 @@
+//go:build ignore
+
+// This program downloads the SPDX license and exception
+// lists into the data directory, to be embedded
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+)
+
+var lists = map[string]string{
+	"data/licenses.json":   "https://spdx.org/licenses/licenses.json",
+	"data/exceptions.json": "https://spdx.org/licenses/exceptions.json",
+}
+
+func main() {
+	for path, url := range lists {
+		err := download(path, url)
+		if err != nil {
+			log.Fatalln("Error downloading", url+":", err)
+		}
+	}
+}
+
+func download(path, url string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("unexpected status: " + resp.Status)
+	}
+
+	fl, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer fl.Close()
+
+	_, err = io.Copy(fl, resp.Body)
+	return err
+}
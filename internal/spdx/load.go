@@ -0,0 +1,242 @@
+package spdx
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mitchellh/go-spdx"
+)
+
+//go:generate go run gen.go
+
+// The embedded lists are snapshots of the SPDX lists, used until
+// newer ones are loaded, so that the analyzer works offline.
+// Run go generate to update them.
+var (
+	//go:embed data/licenses.json
+	embeddedLicenses []byte
+	//go:embed data/exceptions.json
+	embeddedExceptions []byte
+)
+
+// downloadTimeout is the maximum amount of
+// time that downloading a list can take
+const downloadTimeout = 30 * time.Second
+
+func init() {
+	err := setLicenses(embeddedLicenses)
+	if err != nil {
+		panic(err)
+	}
+
+	err = setExceptions(embeddedExceptions)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// maxCacheAge is how long the cached lists are
+// used before Load downloads the latest ones
+const maxCacheAge = 24 * time.Hour
+
+// Load loads the cached lists, and then downloads the
+// latest ones if the cache is missing or older than a day.
+// Errors are only logged, since the embedded lists can be
+// used if both fail.
+func Load() {
+	loadCache()
+	updateStale()
+}
+
+// LoadAsync is like Load, but it downloads the latest lists in
+// the background, so that startup doesn't wait for the download.
+// The cached or embedded lists are used until it finishes.
+func LoadAsync() {
+	loadCache()
+	go updateStale()
+}
+
+// LoadFiles loads the lists from local JSON files, in the same
+// format as https://spdx.org/licenses/licenses.json and
+// https://spdx.org/licenses/exceptions.json. If either
+// path is empty, that list isn't changed.
+func LoadFiles(licensesPath, exceptionsPath string) error {
+	if licensesPath != "" {
+		data, err := os.ReadFile(licensesPath)
+		if err != nil {
+			return err
+		}
+
+		err = setLicenses(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	if exceptionsPath != "" {
+		data, err := os.ReadFile(exceptionsPath)
+		if err != nil {
+			return err
+		}
+
+		err = setExceptions(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func loadCache() {
+	err := LoadCache()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Println("Error loading cached SPDX license list:", err)
+	}
+}
+
+// updateStale updates the lists if the cache is missing
+// or older than maxCacheAge
+func updateStale() {
+	info, err := os.Stat(filepath.Join(cacheDir(), "licenses.json"))
+	if err == nil && time.Since(info.ModTime()) < maxCacheAge {
+		return
+	}
+
+	err = Update()
+	if err != nil {
+		log.Println("Error updating SPDX license list:", err)
+	}
+}
+
+// LoadCache loads the lists that were cached by the last
+// successful update. If there's no cache, the returned
+// error wraps fs.ErrNotExist.
+func LoadCache() error {
+	dir := cacheDir()
+
+	licenses, err := os.ReadFile(filepath.Join(dir, "licenses.json"))
+	if err != nil {
+		return err
+	}
+
+	exceptions, err := os.ReadFile(filepath.Join(dir, "exceptions.json"))
+	if err != nil {
+		return err
+	}
+
+	err = setLicenses(licenses)
+	if err != nil {
+		return err
+	}
+	return setExceptions(exceptions)
+}
+
+// Update downloads the latest lists and caches them
+func Update() error {
+	licenses, err := download(spdx.DefaultListURL)
+	if err != nil {
+		return err
+	}
+
+	exceptions, err := download(ExceptionsURL)
+	if err != nil {
+		return err
+	}
+
+	err = setLicenses(licenses)
+	if err != nil {
+		return err
+	}
+
+	err = setExceptions(exceptions)
+	if err != nil {
+		return err
+	}
+
+	// The lists were updated, so a caching error
+	// shouldn't be reported as an update error
+	err = writeCache(licenses, exceptions)
+	if err != nil {
+		log.Println("Error caching SPDX license list:", err)
+	}
+
+	return nil
+}
+
+func setLicenses(data []byte) error {
+	list := &spdx.LicenseList{}
+	err := json.Unmarshal(data, list)
+	if err != nil {
+		return err
+	}
+
+	Licenses.Lock()
+	Licenses.LicenseList = list
+	Licenses.Unlock()
+
+	return nil
+}
+
+func setExceptions(data []byte) error {
+	list := &ExceptionList{}
+	err := json.Unmarshal(data, list)
+	if err != nil {
+		return err
+	}
+
+	Exceptions.Lock()
+	Exceptions.ExceptionList = list
+	Exceptions.Unlock()
+
+	return nil
+}
+
+func download(url string) ([]byte, error) {
+	client := &http.Client{Timeout: downloadTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status downloading %s: %s", url, resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+func writeCache(licenses, exceptions []byte) error {
+	dir := cacheDir()
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(filepath.Join(dir, "licenses.json"), licenses, 0o644)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "exceptions.json"), exceptions, 0o644)
+}
+
+// cacheDir returns the directory in which
+// the downloaded lists are cached
+func cacheDir() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		cacheDir = os.TempDir()
+	}
+
+	return filepath.Join(cacheDir, "lure-repo-bot", "spdx")
+}
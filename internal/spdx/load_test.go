@@ -0,0 +1,86 @@
+package spdx
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mitchellh/go-spdx"
+)
+
+func TestEmbeddedLists(t *testing.T) {
+	licenses := &spdx.LicenseList{}
+	err := json.Unmarshal(embeddedLicenses, licenses)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exceptions := &ExceptionList{}
+	err = json.Unmarshal(embeddedExceptions, exceptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The snapshots must be the complete lists, since
+	// any missing ID is reported as invalid offline
+	if len(licenses.Licenses) < 500 {
+		t.Errorf("got %d licenses, wanted at least 500", len(licenses.Licenses))
+	}
+	if len(exceptions.Exceptions) < 50 {
+		t.Errorf("got %d exceptions, wanted at least 50", len(exceptions.Exceptions))
+	}
+
+	for _, id := range []string{
+		"MIT", "Apache-2.0", "GPL-2.0-only", "GPL-3.0-or-later", "LGPL-2.1-or-later",
+		"BSD-2-Clause", "BSD-3-Clause", "ISC", "Zlib", "MPL-2.0", "Unlicense",
+		"curl", "libpng-2.0", "OpenSSL", "BSL-1.0", "CC0-1.0", "0BSD",
+	} {
+		if licenses.License(id) == nil {
+			t.Errorf("license %s isn't in the embedded list", id)
+		}
+	}
+
+	if l := licenses.License("GPL-2.0"); l == nil || !l.Deprecated {
+		t.Error("license GPL-2.0 isn't deprecated in the embedded list")
+	}
+
+	for _, id := range []string{
+		"Classpath-exception-2.0", "LLVM-exception", "GCC-exception-3.1",
+		"Autoconf-exception-3.0", "OpenJDK-assembly-exception-1.0",
+	} {
+		if exceptions.Exception(id) == nil {
+			t.Errorf("exception %s isn't in the embedded list", id)
+		}
+	}
+}
+
+// TestConcurrentUpdate checks that the lists can be replaced while
+// they're being used. It's only useful when run with -race.
+func TestConcurrentUpdate(t *testing.T) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5; i++ {
+			if err := setLicenses(embeddedLicenses); err != nil {
+				t.Error(err)
+			}
+			if err := setExceptions(embeddedExceptions); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		if Licenses.License("MIT") == nil {
+			t.Fatal("license MIT not found")
+		}
+		if Exceptions.Exception("LLVM-exception") == nil {
+			t.Fatal("exception LLVM-exception not found")
+		}
+	}
+}
@@ -10,35 +10,36 @@ import (
 	"github.com/mitchellh/go-spdx"
 )
 
+// syncLicenseList is a license list that can be replaced while
+// it's being used. The list must only be read or replaced while
+// the mutex is locked, so its methods use pointer receivers.
 type syncLicenseList struct {
 	*spdx.LicenseList
-	*sync.Mutex
+	sync.Mutex
 }
 
-var Licenses = syncLicenseList{
+var Licenses = &syncLicenseList{
 	LicenseList: &spdx.LicenseList{},
-	Mutex:       &sync.Mutex{},
 }
 
-func (sll syncLicenseList) License(id string) *spdx.LicenseInfo {
+func (sll *syncLicenseList) License(id string) *spdx.LicenseInfo {
 	sll.Lock()
 	l := sll.LicenseList.License(id)
 	sll.Unlock()
 	return l
 }
 
+// StartUpdater loads the license lists and
+// then updates them every hour
 func StartUpdater(ctx context.Context) {
-	err := Update()
-	if err != nil {
-		log.Fatalln("Error updating SPDX license list:", err)
-	}
+	Load()
 
 	ticker := time.NewTicker(time.Hour)
 	go func() {
 		for {
 			select {
 			case <-ticker.C:
-				err = Update()
+				err := Update()
 				if err != nil {
 					log.Println("Error updating SPDX license list:", err)
 				}
@@ -50,30 +51,27 @@ func StartUpdater(ctx context.Context) {
 	}()
 }
 
-func Update() error {
-	list, err := spdx.List()
-	if err != nil {
-		return err
-	}
-	Licenses.Lock()
-	Licenses.LicenseList = list
-	Licenses.Unlock()
-
-	return nil
-}
-
 // findSimilar finds the most similar license ID
 // to the one provided
 func FindSimilarLicense(s string) string {
 	Licenses.Lock()
 	defer Licenses.Unlock()
 
+	ids := make([]string, len(Licenses.Licenses))
+	for i, license := range Licenses.Licenses {
+		ids[i] = license.ID
+	}
+	return findSimilar(s, ids)
+}
+
+// findSimilar returns the ID in ids that's most similar to s
+func findSimilar(s string, ids []string) string {
 	jw := metrics.NewJaroWinkler()
 	jw.CaseSensitive = false
 
-	sims := make([]float64, len(Licenses.Licenses))
-	for i, license := range Licenses.Licenses {
-		sims[i] = jw.Compare(s, license.ID)
+	sims := make([]float64, len(ids))
+	for i, id := range ids {
+		sims[i] = jw.Compare(s, id)
 	}
 
 	index := maxIndex(sims)
@@ -81,7 +79,7 @@ func FindSimilarLicense(s string) string {
 	if index == -1 {
 		return ""
 	} else {
-		return Licenses.Licenses[index].ID
+		return ids[index]
 	}
 }
 
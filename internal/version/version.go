@@ -0,0 +1,193 @@
+// Package version compares package versions the same way
+// LURE does, using rpm's rpmvercmp algorithm.
+package version
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Full is a complete package version, including
+// the epoch and release
+type Full struct {
+	Epoch   uint
+	Version string
+	Release int
+}
+
+// Parse parses a version in the epoch:version-release format.
+// The epoch and release are optional.
+func Parse(s string) (Full, error) {
+	var (
+		out Full
+		err error
+	)
+
+	if epoch, rest, ok := strings.Cut(s, ":"); ok {
+		e, err := strconv.ParseUint(epoch, 10, 0)
+		if err != nil {
+			return Full{}, fmt.Errorf("invalid epoch %q: %w", epoch, err)
+		}
+		out.Epoch = uint(e)
+		s = rest
+	}
+
+	if i := strings.LastIndexByte(s, '-'); i != -1 {
+		out.Release, err = strconv.Atoi(s[i+1:])
+		if err != nil {
+			return Full{}, fmt.Errorf("invalid release %q: %w", s[i+1:], err)
+		}
+		s = s[:i]
+	}
+
+	if s == "" {
+		return Full{}, fmt.Errorf("empty version")
+	}
+	out.Version = s
+
+	return out, nil
+}
+
+func (f Full) String() string {
+	out := f.Version
+	if f.Epoch != 0 {
+		out = strconv.FormatUint(uint64(f.Epoch), 10) + ":" + out
+	}
+	if f.Release != 0 {
+		out += "-" + strconv.Itoa(f.Release)
+	}
+	return out
+}
+
+// Compare compares f to other. The epochs are compared first,
+// then the versions, and then the releases. It returns -1 if f
+// is older, 1 if f is newer, and 0 if they're the same.
+func (f Full) Compare(other Full) int {
+	if f.Epoch != other.Epoch {
+		if f.Epoch < other.Epoch {
+			return -1
+		}
+		return 1
+	}
+
+	if c := Compare(f.Version, other.Version); c != 0 {
+		return c
+	}
+
+	switch {
+	case f.Release < other.Release:
+		return -1
+	case f.Release > other.Release:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Compare compares two version strings. It returns -1 if a
+// is older than b, 1 if a is newer, and 0 if they're the same.
+//
+// Versions are split into segments of digits and letters, and the
+// segments are compared in order. Numeric segments are compared as
+// numbers and are newer than alphabetic ones. Other characters only
+// separate segments, except for ~, which sorts before anything,
+// so that 1.0~rc1 is older than 1.0.
+func Compare(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	for a != "" || b != "" {
+		a = strings.TrimLeftFunc(a, isSeparator)
+		b = strings.TrimLeftFunc(b, isSeparator)
+
+		// A tilde sorts before everything, even the end of the string
+		aTilde, bTilde := strings.HasPrefix(a, "~"), strings.HasPrefix(b, "~")
+		if aTilde || bTilde {
+			if !aTilde {
+				return 1
+			} else if !bTilde {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		if a == "" || b == "" {
+			break
+		}
+
+		// If one segment is numeric and the other isn't,
+		// the numeric one is newer
+		aNum, bNum := isDigit(rune(a[0])), isDigit(rune(b[0]))
+		if aNum != bNum {
+			if aNum {
+				return 1
+			}
+			return -1
+		}
+
+		var aSeg, bSeg string
+		if aNum {
+			aSeg, a = cutSegment(a, isDigit)
+			bSeg, b = cutSegment(b, isDigit)
+			if c := compareNumeric(aSeg, bSeg); c != 0 {
+				return c
+			}
+		} else {
+			aSeg, a = cutSegment(a, isLetter)
+			bSeg, b = cutSegment(b, isLetter)
+			if c := strings.Compare(aSeg, bSeg); c != 0 {
+				return c
+			}
+		}
+	}
+
+	// All the segments are the same, so whichever
+	// version has more segments left is newer
+	switch {
+	case a == "" && b == "":
+		return 0
+	case a == "":
+		return -1
+	default:
+		return 1
+	}
+}
+
+// compareNumeric compares two strings of digits
+// as numbers, without converting them, so that
+// they can't overflow
+func compareNumeric(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func cutSegment(s string, fn func(rune) bool) (seg, rest string) {
+	i := strings.IndexFunc(s, func(r rune) bool { return !fn(r) })
+	if i == -1 {
+		return s, ""
+	}
+	return s[:i], s[i:]
+}
+
+func isSeparator(r rune) bool {
+	return r != '~' && !isDigit(r) && !isLetter(r)
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isLetter(r rune) bool {
+	return r < unicode.MaxASCII && unicode.IsLetter(r)
+}
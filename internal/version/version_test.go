@@ -0,0 +1,117 @@
+package version
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "2.0", -1},
+		{"2.0", "1.0", 1},
+		{"1.9", "1.10", -1},
+		{"1.0", "1.0.1", -1},
+		{"1.0.1", "1.0", 1},
+		{"001", "1", 0},
+		{"1.01", "1.1", 0},
+		{"12345678901234567890", "9", 1},
+
+		// Separators
+		{"1_0", "1.0", 0},
+		{"1.0", "1+0", 0},
+		{"1..0", "1.0", 0},
+
+		// Alphabetic and numeric segments
+		{"1a", "1b", -1},
+		{"1b", "1a", 1},
+		{"a", "1", -1},
+		{"1", "a", 1},
+		{"1.0a", "1.0", 1},
+		{"1.0a", "1.0.1", -1},
+		{"1.0.a", "1.0.1", -1},
+		{"1.0rc1", "1.0rc2", -1},
+		{"1.0alpha", "1.0beta", -1},
+		{"1.0B", "1.0a", -1},
+
+		// Tilde
+		{"1.0~rc1", "1.0", -1},
+		{"1.0", "1.0~rc1", 1},
+		{"1.0~rc1", "1.0~rc2", -1},
+		{"1.0~rc1", "1.0~rc1", 0},
+		{"1.0~~", "1.0~", -1},
+		{"1.0~rc1", "0.9", 1},
+		{"1.0~", "1.0", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			if got := Compare(tt.a, tt.b); got != tt.want {
+				t.Errorf("got %d, wanted %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Full
+		ok   bool
+	}{
+		{"1.0", Full{Version: "1.0"}, true},
+		{"1.0-2", Full{Version: "1.0", Release: 2}, true},
+		{"1:1.0", Full{Epoch: 1, Version: "1.0"}, true},
+		{"2:1.0-3", Full{Epoch: 2, Version: "1.0", Release: 3}, true},
+		{"", Full{}, false},
+		{"1:", Full{}, false},
+		{"-1", Full{}, false},
+		{"a:1.0", Full{}, false},
+		{"1.0-x", Full{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if ok := err == nil; ok != tt.ok || got != tt.want {
+				t.Errorf("got (%+v, %v), wanted (%+v, %v)", got, err, tt.want, tt.ok)
+			}
+			if tt.ok && got.String() != tt.in {
+				t.Errorf("String() = %q, wanted %q", got.String(), tt.in)
+			}
+		})
+	}
+}
+
+func TestFullCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0-1", "1.0-1", 0},
+		{"1.0-1", "1.0-2", -1},
+		{"1.0-10", "1.0-9", 1},
+		{"1.1-1", "1.0-5", 1},
+		{"1:1.0", "2.0", 1},
+		{"2.0", "1:1.0", -1},
+		{"1:1.0-1", "1:1.0-2", -1},
+		{"1:2.0", "2:1.0", -1},
+		{"1.0~rc1-5", "1.0-1", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			a, err := Parse(tt.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := Parse(tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := a.Compare(b); got != tt.want {
+				t.Errorf("got %d, wanted %d", got, tt.want)
+			}
+		})
+	}
+}
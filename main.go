@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"log"
 	"os"
 	"os/signal"
 	"syscall"
@@ -17,7 +18,18 @@ func main() {
 	ctx, cancel := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	spdx.StartUpdater(ctx)
+	// Local lists are never updated, since they're
+	// used when there's no network access
+	licensesPath := os.Getenv("LURE_BOT_SPDX_LIST")
+	exceptionsPath := os.Getenv("LURE_BOT_SPDX_EXCEPTIONS")
+	if licensesPath != "" || exceptionsPath != "" {
+		err := spdx.LoadFiles(licensesPath, exceptionsPath)
+		if err != nil {
+			log.Fatalln("Error loading SPDX license list:", err)
+		}
+	} else {
+		spdx.StartUpdater(ctx)
+	}
 
 	jobQueue := queue.New[*types.PullRequestPayload]()
 
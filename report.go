@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"go.arsenm.dev/lure-repo-bot/internal/analyze"
+	"golang.org/x/exp/slices"
+)
+
+// unanchoredFinding is a finding that couldn't
+// be attached to a line in the PR diff
+type unanchoredFinding struct {
+	Path    string
+	Finding analyze.Finding
+}
+
+// summaryReport generates the markdown body of a PR review
+func summaryReport(results []fileResult, unanchored []unanchoredFinding) string {
+	sb := &strings.Builder{}
+
+	sb.WriteString("## Summary\n\n")
+	sb.WriteString("| File | Name | Version | Release | Errors | Warnings |\n")
+	sb.WriteString("|------|------|---------|---------|--------|----------|\n")
+
+	var rules []string
+	total := 0
+	for _, result := range results {
+		counts := map[analyze.Severity]int{}
+		for _, finding := range result.Findings {
+			counts[finding.Severity]++
+			if finding.Rule != "" && !slices.Contains(rules, finding.Rule) {
+				rules = append(rules, finding.Rule)
+			}
+		}
+		total += len(result.Findings)
+
+		fmt.Fprintf(
+			sb,
+			"| %s | %s | %s | %s | %d | %d |\n",
+			mdCell(result.Path),
+			mdCell(result.Name),
+			mdCell(result.Version),
+			mdCell(result.Release),
+			counts[analyze.SeverityError],
+			counts[analyze.SeverityWarning],
+		)
+	}
+
+	if len(unanchored) > 0 {
+		sb.WriteString("\n## Other findings\n\n")
+		sb.WriteString("These findings couldn't be attached to a changed line:\n\n")
+		for _, uf := range unanchored {
+			// Each finding has to stay on a single line of the list
+			msg := strings.ReplaceAll(findingMsg(uf.Finding), "\n\n", " ")
+			msg = strings.ReplaceAll(msg, "\n", " ")
+			fmt.Fprintf(sb, "- **%s** %s: %s\n", uf.Finding.Severity, mdCode(uf.Path), msg)
+		}
+	}
+
+	if len(rules) > 0 {
+		slices.Sort(rules)
+		sb.WriteString("\n## Rules\n\n")
+		for _, rule := range rules {
+			fmt.Fprintf(sb, "- [%s](%s)\n", rule, analyze.RuleDocURL(rule))
+		}
+	}
+
+	sb.WriteString("\n")
+	if total > 0 {
+		sb.WriteString("Please re-request review from the bot after applying these fixes")
+	} else {
+		sb.WriteString("No issues found!")
+	}
+
+	return sb.String()
+}
+
+// mdCode wraps s in backticks, or returns a placeholder if s
+// is empty. The values come from scripts and PRs, so they're
+// escaped to keep them from ending the code span.
+func mdCode(s string) string {
+	if s == "" {
+		return "*unset*"
+	}
+
+	// Code spans can't contain blank lines, and the values
+	// are used in tables and lists, which are line-based
+	s = newlineReplacer.Replace(s)
+
+	// A code span ends at a run of backticks as long as the one
+	// that started it, so the fence has to be longer than any
+	// run in s. Spaces keep backticks at the edges of s from
+	// being part of the fence, and are stripped when rendered.
+	longest, run := 0, 0
+	for _, c := range s {
+		if c == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", longest+1)
+
+	if s[0] == '`' || s[len(s)-1] == '`' || (s[0] == ' ' && s[len(s)-1] == ' ') {
+		s = " " + s + " "
+	}
+	return fence + s + fence
+}
+
+// mdCell is like mdCode, but also escapes pipes, which
+// end a table cell even inside of a code span
+func mdCell(s string) string {
+	return strings.ReplaceAll(mdCode(s), "|", `\|`)
+}
+
+var newlineReplacer = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ")
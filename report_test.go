@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"go.arsenm.dev/lure-repo-bot/internal/analyze"
+	"golang.org/x/exp/slices"
+)
+
+func TestMdCode(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		code string
+		cell string
+	}{
+		{"empty", "", "*unset*", "*unset*"},
+		{"plain", "1.0", "`1.0`", "`1.0`"},
+		{"backtick", "1`0", "``1`0``", "``1`0``"},
+		{"backtick run", "a``b`c", "```a``b`c```", "```a``b`c```"},
+		{"backtick at edges", "`foo`", "`` `foo` ``", "`` `foo` ``"},
+		{"spaces at edges", " foo ", "`  foo  `", "`  foo  `"},
+		{"newline", "foo\nbar\r\nbaz", "`foo bar baz`", "`foo bar baz`"},
+		{"pipe", "foo|bar", "`foo|bar`", "`foo\\|bar`"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := mdCode(tt.in); code != tt.code {
+				t.Errorf("mdCode: got %q, wanted %q", code, tt.code)
+			}
+			if cell := mdCell(tt.in); cell != tt.cell {
+				t.Errorf("mdCell: got %q, wanted %q", cell, tt.cell)
+			}
+		})
+	}
+}
+
+func TestSummaryReport(t *testing.T) {
+	results := []fileResult{
+		{Path: "foo/lure.sh", Name: "foo", Version: "1.0", Release: "1"},
+		{Path: "bar/lure.sh", Name: "bar | baz", Version: "1`0", Release: "1\n| x | y |"},
+		{Path: "unset/lure.sh"},
+	}
+	unanchored := []unanchoredFinding{{
+		Path: "foo/lure.sh",
+		Finding: analyze.Finding{
+			ItemType: "variable",
+			ItemName: "version",
+			Msg:      "The %s is invalid: 'a\nb'.",
+			Rule:     analyze.RuleVersion,
+			Severity: analyze.SeverityError,
+		},
+	}}
+	results[0].Findings = []analyze.Finding{unanchored[0].Finding}
+
+	report := summaryReport(results, unanchored)
+	lines := strings.Split(report, "\n")
+
+	rows := []string{
+		"| `foo/lure.sh` | `foo` | `1.0` | `1` | 1 | 0 |",
+		"| `bar/lure.sh` | `bar \\| baz` | ``1`0`` | `1 \\| x \\| y \\|` | 0 | 0 |",
+		"| `unset/lure.sh` | *unset* | *unset* | *unset* | 0 | 0 |",
+	}
+	for i, row := range rows {
+		// The rows come after the title, blank line, header, and delimiter
+		if got := lines[4+i]; got != row {
+			t.Errorf("row %d: got %q, wanted %q", i, got, row)
+		}
+	}
+
+	item := "- **error** `foo/lure.sh`: The `version` variable is invalid: 'a b'. [version](" + analyze.RuleDocURL(analyze.RuleVersion) + ")"
+	if !slices.Contains(lines, item) {
+		t.Errorf("got report %q, wanted it to contain %q", report, item)
+	}
+
+	if !strings.HasSuffix(report, "Please re-request review from the bot after applying these fixes") {
+		t.Errorf("got report %q, wanted it to ask for a re-review", report)
+	}
+}
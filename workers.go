@@ -2,175 +2,236 @@ package main
 
 import (
 	"context"
-	"fmt"
+	"io/fs"
 	"log"
 	"os"
+	pathpkg "path"
+	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
-	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/plumbing"
-	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/google/go-github/v48/github"
 	"go.arsenm.dev/lure-repo-bot/internal/analyze"
-	"go.arsenm.dev/lure-repo-bot/internal/shutils"
-	"mvdan.cc/sh/v3/expand"
-	"mvdan.cc/sh/v3/interp"
+	"go.arsenm.dev/lure-repo-bot/internal/fetch"
+	"go.arsenm.dev/lure-repo-bot/internal/sandbox"
+	"go.arsenm.dev/lure-repo-bot/internal/types"
 	"mvdan.cc/sh/v3/syntax"
 )
 
+// jobTimeout is the maximum amount of time
+// that reviewing a single PR can take
+const jobTimeout = 5 * time.Minute
+
 func startWebhookWorkers(ctx context.Context, jobQueue prQueue) {
 	client := newClient(ctx, os.Getenv("LURE_BOT_GITHUB_TOKEN"))
 
+	mirror := &fetch.Mirror{Dir: mirrorDir()}
+	mirror.StartGC(ctx)
+
+	// Try to download only the changed files first, and
+	// only use the full mirror if that doesn't work
+	fetcher := fetch.Chain(
+		fetch.API{Client: client},
+		fetch.Shallow{},
+		mirror,
+	)
+
+	// Checking that URLs are reachable is optional,
+	// since it needs network access
+	var reach *analyze.Reachability
+	if os.Getenv("LURE_BOT_CHECK_URLS") == "1" {
+		reach = &analyze.Reachability{}
+	}
+
 	for i := 0; i < runtime.NumCPU(); i++ {
-		go startWebhookWorker(ctx, jobQueue, client)
+		// Repo-wide checks need the scripts of every package, which
+		// only the mirror provides. If it fails, they're skipped.
+		go startWebhookWorker(ctx, jobQueue, client, fetcher, mirror, reach)
 	}
 }
 
-func startWebhookWorker(ctx context.Context, jobQueue prQueue, client *github.Client) {
+func startWebhookWorker(ctx context.Context, jobQueue prQueue, client *github.Client, fetcher, repoFetcher fetch.Fetcher, reach *analyze.Reachability) {
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case payload := <-jobQueue.Channel():
-			if payload.Action == "opened" || payload.Action == "ready_for_review" || payload.Action == "review_requested" {
-				if payload.PullRequest.Draft {
-					continue
-				}
-
-				// Check if review was requested from the bot
-				if payload.Action == "review_requested" {
-					user, _, err := client.Users.Get(ctx, "")
-					if err != nil {
-						log.Println("Error getting github user:", err)
-						continue
-					}
-
-					found := false
-					for _, reviewer := range payload.PullRequest.RequestedReviewers {
-						if reviewer.ID == *user.ID {
-							found = true
-							break
-						}
-					}
-
-					if !found {
-						continue
-					}
-				}
-
-				fls, _, err := client.PullRequests.ListFiles(
-					ctx,
-					payload.PullRequest.Base.Repo.Owner.Login,
-					payload.PullRequest.Base.Repo.Name,
-					int(payload.PullRequest.Number),
-					nil,
-				)
-				if err != nil {
-					log.Println("Error listing PR files:", err)
-					continue
-				}
-
-				head := payload.PullRequest.Head
-
-				tmpdir, err := os.MkdirTemp("/tmp", "lure-repo-bot.*")
-				if err != nil {
-					log.Println("Error creating temporary directory:", err)
-					continue
-				}
-
-				r, err := git.PlainClone(tmpdir, true, &git.CloneOptions{URL: head.Repo.HTMLURL})
-				if err != nil {
-					log.Println("Error cloning git repo:", err)
-					continue
-				}
-
-				files, err := getFiles(r, head.Sha)
-				if err != nil {
-					log.Println("Error getting files in repo:", err)
-					continue
-				}
-
-				err = files.ForEach(func(f *object.File) error {
-					if !strings.Contains(f.Name, "lure.sh") {
-						return nil
-					}
-
-					if !fileInPR(fls, f) {
-						return nil
-					}
-
-					fmt.Println(f.Name, fileInPR(fls, f))
-
-					r, err := f.Reader()
-					if err != nil {
-						return err
-					}
-
-					fl, err := syntax.NewParser().Parse(r, "lure.sh")
-					if err != nil {
-						return err
-					}
-
-					var nopRWC shutils.NopRWC
-					runner, err := interp.New(
-						interp.Env(expand.ListEnviron()),
-						interp.StdIO(nopRWC, nopRWC, os.Stderr),
-						interp.ExecHandler(shutils.NopExec),
-						interp.ReadDirHandler(shutils.NopReadDir),
-						interp.OpenHandler(shutils.NopOpen),
-						interp.StatHandler(shutils.NopStat),
-					)
-					if err != nil {
-						return err
-					}
-
-					err = runner.Run(ctx, fl)
-					if err != nil {
-						return err
-					}
-
-					findings, err := analyze.AnalyzeScript(runner, fl)
-					if err != nil {
-						return err
-					}
-
-					return writeFindings(ctx, client, findings, f.Name, &payload.PullRequest)
-				})
-				if err != nil {
-					log.Println("Error analyzing files:", err)
-					continue
-				}
-
-				err = os.RemoveAll(tmpdir)
-				if err != nil {
-					log.Println("Error removing temporary directory:", err)
-					continue
-				}
-			}
+			jobCtx, cancel := context.WithTimeout(ctx, jobTimeout)
+			handlePayload(jobCtx, client, fetcher, repoFetcher, reach, payload)
+			cancel()
 		}
 	}
 }
 
-func fileInPR(prFiles []*github.CommitFile, file *object.File) bool {
-	for _, prFile := range prFiles {
-		if *prFile.Filename == file.Name {
-			return true
+// handlePayload reviews the PR in payload if needed
+func handlePayload(ctx context.Context, client *github.Client, fetcher, repoFetcher fetch.Fetcher, reach *analyze.Reachability, payload *types.PullRequestPayload) {
+	if payload.Action != "opened" && payload.Action != "ready_for_review" && payload.Action != "review_requested" {
+		return
+	}
+
+	if payload.PullRequest.Draft {
+		return
+	}
+
+	// Check if review was requested from the bot
+	if payload.Action == "review_requested" {
+		user, _, err := client.Users.Get(ctx, "")
+		if err != nil {
+			log.Println("Error getting github user:", err)
+			return
+		}
+
+		found := false
+		for _, reviewer := range payload.PullRequest.RequestedReviewers {
+			if reviewer.ID == *user.ID {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return
 		}
 	}
-	return false
-}
 
-func getFiles(r *git.Repository, sha string) (*object.FileIter, error) {
-	co, err := r.CommitObject(plumbing.NewHash(sha))
+	fls, err := listFiles(ctx, client, &payload.PullRequest)
+	if err != nil {
+		log.Println("Error listing PR files:", err)
+		return
+	}
+
+	var paths []string
+	for _, prFile := range fls {
+		if !strings.Contains(prFile.GetFilename(), "lure.sh") || prFile.GetStatus() == "removed" {
+			continue
+		}
+		paths = append(paths, prFile.GetFilename())
+	}
+
+	if len(paths) == 0 {
+		return
+	}
+
+	fsys, err := fetcher.Fetch(ctx, &payload.PullRequest, paths)
+	if err != nil {
+		log.Println("Error fetching PR files:", err)
+		return
+	}
+
+	// The repo-wide checks are skipped if the
+	// whole repository can't be fetched
+	repoFS, err := repoFetcher.Fetch(ctx, &payload.PullRequest, paths)
+	if err != nil {
+		log.Println("Error fetching repository:", err)
+	}
+
+	results, err := analyzeFiles(ctx, fsys, repoFS, fls, paths, reach)
+	if err != nil {
+		log.Println("Error analyzing files:", err)
+		return
+	}
+
+	err = writeReview(ctx, client, results, &payload.PullRequest)
 	if err != nil {
-		return nil, err
+		log.Println("Error writing review:", err)
+		return
+	}
+}
+
+// mirrorDir returns the directory in which
+// the repository mirror should be stored
+func mirrorDir() string {
+	if dir, ok := os.LookupEnv("LURE_BOT_MIRROR_DIR"); ok {
+		return dir
 	}
 
-	tree, err := co.Tree()
+	cacheDir, err := os.UserCacheDir()
 	if err != nil {
-		return nil, err
+		cacheDir = os.TempDir()
+	}
+
+	return filepath.Join(cacheDir, "lure-repo-bot", "mirror")
+}
+
+// analyzeFiles analyzes the scripts at paths in fsys. If repoFS
+// isn't nil, the scripts are also checked against the rest of the
+// repository, and if reach isn't nil, it's used to check their URLs.
+func analyzeFiles(ctx context.Context, fsys, repoFS fs.FS, fls []*github.CommitFile, paths []string, reach *analyze.Reachability) ([]fileResult, error) {
+	results := make([]fileResult, 0, len(paths))
+	for _, path := range paths {
+		fl, err := fsys.Open(path)
+		if err != nil {
+			return nil, err
+		}
+
+		sfl, err := syntax.NewParser().Parse(fl, "lure.sh")
+		fl.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		var patch string
+		if prFile, ok := findPRFile(fls, path); ok {
+			patch = prFile.GetPatch()
+		}
+
+		pkgFS, err := fs.Sub(fsys, pathpkg.Dir(path))
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := sandbox.Evaluate(ctx, sfl, pkgFS)
+		if finding, ok := analyze.EvalFinding(err); ok {
+			results = append(results, fileResult{
+				Path:      path,
+				Findings:  []analyze.Finding{finding},
+				DiffLines: parsePatch(patch),
+			})
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		var opts analyze.Options
+		if dir := pathpkg.Dir(path); dir != "." {
+			opts.Dir = pathpkg.Base(dir)
+		}
+
+		findings, err := analyze.AnalyzeScript(res, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		if repoFS != nil {
+			repoFindings, err := analyze.RepoFindings(res, pathpkg.Dir(path), repoFS)
+			if err != nil {
+				return nil, err
+			}
+			findings = append(findings, repoFindings...)
+		}
+
+		if reach != nil {
+			findings = append(findings, reach.Check(ctx, res)...)
+		}
+
+		results = append(results, fileResult{
+			Path:      path,
+			Name:      res.Runner.Vars["name"].String(),
+			Version:   res.Runner.Vars["version"].String(),
+			Release:   res.Runner.Vars["release"].String(),
+			Findings:  findings,
+			DiffLines: parsePatch(patch),
+		})
 	}
+	return results, nil
+}
 
-	return tree.Files(), nil
+func findPRFile(prFiles []*github.CommitFile, path string) (*github.CommitFile, bool) {
+	for _, prFile := range prFiles {
+		if prFile.GetFilename() == path {
+			return prFile, true
+		}
+	}
+	return nil, false
 }
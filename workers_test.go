@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"go.arsenm.dev/lure-repo-bot/internal/analyze"
+)
+
+func TestAnalyzeFilesFailingScript(t *testing.T) {
+	const script = "name=foo\nversion=1.0\nrelease=1\npackage() { :; }\n"
+
+	tests := []struct {
+		name string
+		last string
+		rule string
+		item string
+	}{
+		{"failed test", `[ -n "" ] && x=1`, "", ""},
+		{"denied builtin", "read -r x", analyze.RuleEvaluation, "read"},
+		{"blocked write", ": > /tmp/foo", analyze.RuleFileAccess, "/tmp/foo"},
+		{"exit", "exit 1", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{
+				"foo/lure.sh": {Data: []byte(script + tt.last + "\n")},
+			}
+
+			results, err := analyzeFiles(context.Background(), fsys, nil, nil, []string{"foo/lure.sh"}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(results) != 1 {
+				t.Fatalf("got %d results, wanted 1", len(results))
+			}
+			res := results[0]
+
+			// The script's variables are still read
+			if res.Name != "foo" || res.Version != "1.0" || res.Release != "1" {
+				t.Errorf("got %s %s-%s, wanted foo 1.0-1", res.Name, res.Version, res.Release)
+			}
+
+			if tt.rule == "" {
+				if len(res.Findings) != 0 {
+					t.Errorf("got findings %v, wanted none", res.Findings)
+				}
+				return
+			}
+
+			found := false
+			for _, f := range res.Findings {
+				if f.Rule == tt.rule && f.ItemName == tt.item {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("got findings %v, wanted a %s finding for %s", res.Findings, tt.rule, tt.item)
+			}
+		})
+	}
+}